@@ -25,6 +25,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -117,6 +119,432 @@ func TestMessageRepository(t *testing.T) {
 	})
 }
 
+func TestFindManyContextCancellation(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test FindMany exits its goroutine when ctx is cancelled mid-stream", t, func() {
+		p := filepath.Join(os.TempDir(), "logtest-"+uuid.New()+".bleve")
+		dsn := p + "?mapping=log"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() {
+			_ = idx.Close(ctx)
+			_ = os.RemoveAll(p)
+		}()
+
+		s, err := NewIndexService(idx)
+		So(err, ShouldBeNil)
+		for i := 0; i < 50; i++ {
+			So(s.PutLog(ctx, log2map("INFO", fmt.Sprintf("cancellation test message %d", i))), ShouldBeNil)
+		}
+		<-time.After(4 * time.Second)
+
+		findCtx, cancel := context.WithCancel(ctx)
+		results, err := idx.FindMany(findCtx, "", 0, 50, "", false, nil)
+		So(err, ShouldBeNil)
+
+		_, ok := <-results
+		So(ok, ShouldBeTrue)
+		cancel()
+
+		done := make(chan struct{})
+		go func() {
+			for range results {
+			}
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("FindMany goroutine did not exit after context cancellation")
+		}
+	})
+}
+
+func TestFindManyFamilyContextCancellation(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test every Find*/Search* constructor exits its goroutine when ctx is cancelled mid-stream", t, func() {
+		p := filepath.Join(os.TempDir(), "logtest-"+uuid.New()+".bleve")
+		dsn := p + "?mapping=log"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() {
+			_ = idx.Close(ctx)
+			_ = os.RemoveAll(p)
+		}()
+
+		s, err := NewIndexService(idx)
+		So(err, ShouldBeNil)
+		for i := 0; i < 50; i++ {
+			So(s.PutLog(ctx, log2map("INFO", fmt.Sprintf("cancellation test message %d", i))), ShouldBeNil)
+		}
+		<-time.After(4 * time.Second)
+
+		bi := idx.(*bleve.Indexer)
+		from := time.Now().Add(-time.Hour)
+		to := time.Now().Add(time.Hour)
+
+		// Every constructor below returns a chan interface{} fed by a background goroutine, and
+		// is expected to honor the same contract as FindMany (see TestFindManyContextCancellation):
+		// cancelling ctx must make that goroutine exit even if the caller stops draining the
+		// channel, instead of leaking it forever on a blocked send.
+		starters := map[string]func(context.Context) (chan interface{}, error){
+			"FindManyWithSort": func(c context.Context) (chan interface{}, error) {
+				return bi.FindManyWithSort(c, "", 0, 50, nil, nil)
+			},
+			"FindManyAfter": func(c context.Context) (chan interface{}, error) {
+				res, _, err := bi.FindManyAfter(c, "", []string{"_id"}, nil, 50, nil)
+				return res, err
+			},
+			"FindManyWithHighlight": func(c context.Context) (chan interface{}, error) {
+				return bi.FindManyWithHighlight(c, "", 0, 50, "", false, nil, nil)
+			},
+			"FindManyTyped": func(c context.Context) (chan interface{}, error) {
+				return bi.FindManyTyped(c, "", 0, 50, "", false, nil)
+			},
+			"SearchTimeRange": func(c context.Context) (chan interface{}, error) {
+				return bi.SearchTimeRange(c, "", common.KeyTs, from, to, 0, 50)
+			},
+			"SearchTimeWindow": func(c context.Context) (chan interface{}, error) {
+				return bi.SearchTimeWindow(c, "", common.KeyTs, from, to, 0, 50)
+			},
+			"FindManyWithTimeHistogram": func(c context.Context) (chan interface{}, error) {
+				return bi.FindManyWithTimeHistogram(c, "", 0, 50, common.KeyTs, from, to, time.Minute, nil)
+			},
+			"FindManyWithAggregation": func(c context.Context) (chan interface{}, error) {
+				// BuildQuery already requests every field via "*"; aggregating on a field
+				// that isn't separately requested avoids bleve returning it twice as a
+				// list, which would trip up unmarshalling unrelated to what's under test
+				// here (cancellation, not aggregation correctness).
+				return bi.FindManyWithAggregation(c, "", 0, 50, "", false, "NonAggregatedField", nil)
+			},
+			"FindManyWithAnalyzer": func(c context.Context) (chan interface{}, error) {
+				return bi.FindManyWithAnalyzer(c, "", 0, 50, "", false, "", nil)
+			},
+		}
+
+		// SearchTimeRange and SearchTimeWindow filter on a date-typed field, which this log
+		// index doesn't map Ts as (it's stored as a plain epoch-seconds number), so they never
+		// actually match a hit here. They're still worth covering: draining must complete
+		// promptly after cancel either way, so the assertion on the first receive is skipped
+		// only for these two.
+		requiresHit := map[string]bool{
+			"SearchTimeRange":  false,
+			"SearchTimeWindow": false,
+		}
+
+		for name, start := range starters {
+			name, start := name, start
+			Convey(name+" exits its goroutine when ctx is cancelled mid-stream", func() {
+				findCtx, cancel := context.WithCancel(ctx)
+				results, err := start(findCtx)
+				So(err, ShouldBeNil)
+
+				_, ok := <-results
+				if want, known := requiresHit[name]; !known || want {
+					So(ok, ShouldBeTrue)
+				}
+				cancel()
+
+				done := make(chan struct{})
+				go func() {
+					for range results {
+					}
+					close(done)
+				}()
+
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Fatal(name + " goroutine did not exit after context cancellation")
+				}
+			})
+		}
+	})
+}
+
+func TestStreamAll(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test StreamAll exports every indexed document", t, func() {
+		p := filepath.Join(os.TempDir(), "logtest-"+uuid.New()+".bleve")
+		dsn := p + "?mapping=log"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() {
+			_ = idx.Close(ctx)
+			_ = os.RemoveAll(p)
+		}()
+
+		s, err := NewIndexService(idx)
+		So(err, ShouldBeNil)
+		const total = 30
+		for i := 0; i < total; i++ {
+			So(s.PutLog(ctx, log2map("INFO", fmt.Sprintf("stream all message %d", i))), ShouldBeNil)
+		}
+		<-time.After(4 * time.Second)
+
+		results, err := idx.(*bleve.Indexer).StreamAll(ctx, nil)
+		So(err, ShouldBeNil)
+		count := 0
+		for range results {
+			count++
+		}
+		So(count, ShouldEqual, total)
+	})
+}
+
+func TestDeleteByIDs(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test DeleteByIDs removes nothing for an empty or unknown set of IDs", t, func() {
+		p := filepath.Join(os.TempDir(), "logtest-"+uuid.New()+".bleve")
+		dsn := p + "?mapping=log"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() {
+			_ = idx.Close(ctx)
+			_ = os.RemoveAll(p)
+		}()
+
+		s, err := NewIndexService(idx)
+		So(err, ShouldBeNil)
+		const total = 10
+		for i := 0; i < total; i++ {
+			So(s.PutLog(ctx, log2map("INFO", fmt.Sprintf("delete by ids message %d", i))), ShouldBeNil)
+		}
+		<-time.After(4 * time.Second)
+
+		bi := idx.(*bleve.Indexer)
+		before, err := bi.DocCount()
+		So(err, ShouldBeNil)
+		So(before, ShouldEqual, uint64(total))
+
+		removed, err := bi.DeleteByIDs(ctx, nil)
+		So(err, ShouldBeNil)
+		So(removed, ShouldEqual, int32(0))
+
+		removed, err = bi.DeleteByIDs(ctx, []string{"does-not-exist-1", "does-not-exist-2"})
+		So(err, ShouldBeNil)
+		So(removed, ShouldEqual, int32(0))
+
+		after, err := bi.DocCount()
+		So(err, ShouldBeNil)
+		So(after, ShouldEqual, before)
+	})
+}
+
+func TestHealthy(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test Healthy reports readiness and detects a closed indexer", t, func() {
+		p := filepath.Join(os.TempDir(), "logtest-"+uuid.New()+".bleve")
+		dsn := p + "?mapping=log"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() { _ = os.RemoveAll(p) }()
+
+		bi := idx.(*bleve.Indexer)
+		So(bi.Healthy(), ShouldBeNil)
+
+		So(idx.Close(ctx), ShouldBeNil)
+		So(bi.Healthy(), ShouldNotBeNil)
+	})
+}
+
+func TestStoreConfig(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test SetStoreConfig merges into the store kvConfig without breaking index creation", t, func() {
+		p := filepath.Join(os.TempDir(), "logtest-"+uuid.New()+".bleve")
+		dsn := p + "?mapping=log"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+
+		bi := idx.(*bleve.Indexer)
+		bi.SetStoreConfig(map[string]interface{}{"bolt_timeout": "1s"})
+
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() {
+			_ = idx.Close(ctx)
+			_ = os.RemoveAll(p)
+		}()
+
+		s, err := NewIndexService(idx)
+		So(err, ShouldBeNil)
+		So(s.PutLog(ctx, log2map("INFO", "store config message")), ShouldBeNil)
+	})
+}
+
+func TestReload(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test Reload picks up an externally-added rotated segment", t, func() {
+		dir := filepath.Join(os.TempDir(), uuid.New())
+		p := filepath.Join(dir, "syslog.bleve")
+		_ = os.MkdirAll(dir, 0777)
+		dsn := p + "?mapping=log"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() {
+			_ = idx.Close(ctx)
+			_ = os.RemoveAll(dir)
+		}()
+
+		s, err := NewIndexService(idx)
+		So(err, ShouldBeNil)
+		So(s.PutLog(ctx, log2map("INFO", "before reload")), ShouldBeNil)
+		<-time.After(4 * time.Second)
+
+		before := idx.Stats()
+		So(before["indexes"], ShouldHaveLength, 1)
+
+		// Simulate a rotated segment appearing out-of-band, e.g. an rsync from a primary.
+		otherDAO, err := bleve.NewDAO(ctx, "bleve", p+".0001?mapping=log", "")
+		So(err, ShouldBeNil)
+		otherIdx, err := bleve.NewIndexer(ctx, otherDAO)
+		So(err, ShouldBeNil)
+		otherIdx.SetCodex(&BleveCodec{})
+		So(otherIdx.Init(ctx, configx.New()), ShouldBeNil)
+		otherService, err := NewIndexService(otherIdx)
+		So(err, ShouldBeNil)
+		So(otherService.PutLog(ctx, log2map("INFO", "rotated elsewhere")), ShouldBeNil)
+		<-time.After(4 * time.Second)
+		So(otherIdx.Close(ctx), ShouldBeNil)
+
+		So(idx.(*bleve.Indexer).Reload(ctx), ShouldBeNil)
+		after := idx.Stats()
+		So(after["indexes"], ShouldHaveLength, 2)
+		So(after["docsCount"], ShouldEqual, uint64(2))
+	})
+}
+
+func TestCustomSegmentNaming(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test SetNameForRotation names and discovers segments with a custom scheme", t, func() {
+		dir := filepath.Join(os.TempDir(), uuid.New())
+		p := filepath.Join(dir, "syslog.bleve")
+		_ = os.MkdirAll(dir, 0777)
+		dsn := p + "?mapping=log&rotationDocCount=1"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+
+		var namedMu sync.Mutex
+		var named []string
+		bi := idx.(*bleve.Indexer)
+		bi.SetNameForRotation(
+			func(base string, seq int, t time.Time) string {
+				if seq == 0 {
+					return base
+				}
+				name := fmt.Sprintf("%s-seg%d", base, seq)
+				namedMu.Lock()
+				named = append(named, name)
+				namedMu.Unlock()
+				return name
+			},
+			func(curBase, base string) bool {
+				return curBase == base || strings.HasPrefix(curBase, base+"-seg")
+			},
+		)
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() {
+			_ = idx.Close(ctx)
+			_ = os.RemoveAll(dir)
+		}()
+
+		s, err := NewIndexService(idx)
+		So(err, ShouldBeNil)
+		So(s.PutLog(ctx, log2map("INFO", "first segment")), ShouldBeNil)
+		So(s.PutLog(ctx, log2map("INFO", "second segment")), ShouldBeNil)
+		<-time.After(4 * time.Second)
+
+		namedMu.Lock()
+		gotNamed := append([]string(nil), named...)
+		namedMu.Unlock()
+		So(gotNamed, ShouldNotBeEmpty)
+		entries, err := os.ReadDir(dir)
+		So(err, ShouldBeNil)
+		var foundCustom bool
+		for _, e := range entries {
+			if strings.Contains(e.Name(), "-seg") {
+				foundCustom = true
+			}
+		}
+		So(foundCustom, ShouldBeTrue)
+
+		stats := idx.Stats()
+		So(len(stats["indexes"].([]string)), ShouldBeGreaterThanOrEqualTo, 2)
+	})
+}
+
+func TestInsertCloseRace(t *testing.T) {
+	ctx := context.Background()
+	Convey("Test InsertOne does not race or panic against a concurrent Close", t, func() {
+		p := filepath.Join(os.TempDir(), "logtest-"+uuid.New()+".bleve")
+		dsn := p + "?mapping=log"
+
+		daoInst, err := bleve.NewDAO(ctx, "bleve", dsn, "")
+		So(err, ShouldBeNil)
+		idx, err := bleve.NewIndexer(ctx, daoInst)
+		So(err, ShouldBeNil)
+		idx.SetCodex(&BleveCodec{})
+		So(idx.Init(ctx, configx.New()), ShouldBeNil)
+		defer func() { _ = os.RemoveAll(p) }()
+
+		s, err := NewIndexService(idx)
+		So(err, ShouldBeNil)
+
+		var wg sync.WaitGroup
+		for n := 0; n < 20; n++ {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					_ = s.PutLog(ctx, log2map("INFO", fmt.Sprintf("race message %d-%d", n, j)))
+				}
+			}(n)
+		}
+		// Close races against the InsertOne calls above: under -race this must neither panic
+		// (send on a closed channel) nor report a data race on the opened flag.
+		So(idx.Close(ctx), ShouldBeNil)
+		wg.Wait()
+	})
+}
+
 func TestSizeRotation(t *testing.T) {
 	bleve.UnitTestEnv = true
 	ctx := context.Background()
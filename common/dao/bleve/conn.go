@@ -25,6 +25,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pydio/cells/v4/common/dao"
 )
@@ -37,13 +38,217 @@ type BleveConfig struct {
 	MappingName  string
 	RotationSize int64
 	BatchSize    int64
+	// StoreSource, when true, keeps a copy of the original marshalled document under a
+	// "_source" field so it can be returned verbatim even when the mapping drops or mangles
+	// some fields. Only applies when the codec marshals documents as map[string]interface{}.
+	StoreSource bool
+	// TimeField is the default field name used by SearchTimeRange when the caller does not
+	// specify one explicitly.
+	TimeField string
+	// AliasRefreshInterval, when greater than zero, defers adding newly rotated segments to
+	// the search alias until the next tick of this interval, trading search freshness for
+	// smoother search performance during heavy rotation. Zero means immediate (default).
+	AliasRefreshInterval time.Duration
+	// ThrottleHighWaterMark and ThrottleMaxBlock configure an adaptive backpressure mode for
+	// InsertOne: once the pending inserts queue grows past this many items, InsertOne blocks
+	// (up to ThrottleMaxBlock) instead of dropping immediately, smoothing transient bursts.
+	// Zero disables throttling (default, preserves the non-blocking drop behavior).
+	ThrottleHighWaterMark int
+	ThrottleMaxBlock      time.Duration
+	// DuplicatePolicy controls what happens when two documents with the same IndexID() are
+	// staged into the same batch before it is flushed: DuplicatePolicyLastWins (default) keeps
+	// the last one, DuplicatePolicyFirstWins keeps the first and drops later ones, and
+	// DuplicatePolicyError drops later ones and counts them as errors instead of silently
+	// applying either policy.
+	DuplicatePolicy string
+	// RotationWarmup defers the rotation-size check for this long after Open, so that an
+	// already-oversized segment loaded from a previous run is not rotated again immediately on
+	// every restart. Zero (default) disables the warm-up, preserving the previous behavior of
+	// checking for rotation right away.
+	RotationWarmup time.Duration
+	// TTLField, when set, enables a periodic expiry sweep that deletes documents whose value for
+	// this date field is in the past. TTLSweepInterval configures how often the sweep runs,
+	// TTLSweepBatchSize bounds how many documents are deleted per batch, and TTLSweepPause is
+	// slept between batches so a large backlog of expired documents doesn't spike load.
+	TTLField          string
+	TTLSweepInterval  time.Duration
+	TTLSweepBatchSize int
+	TTLSweepPause     time.Duration
+	// AliasSearchConcurrency caps how many rotated segments are searched in parallel for a
+	// single query. Zero (default) leaves bleve's alias search unbounded, searching every
+	// segment concurrently.
+	AliasSearchConcurrency int
+	// RotationDocCount, if set, makes rotateIfNeeded also rotate once the number of documents
+	// staged into the active segment since it became the write segment reaches it (a running
+	// counter; DocCount() is not called), independently of RotationSize. Whichever threshold is hit first
+	// triggers the rotation. Zero (default) disables document-count-based rotation.
+	RotationDocCount int64
+	// RotationInterval, if set, makes rotateIfNeeded also rotate once the active write segment
+	// is older than this, independently of RotationSize and RotationDocCount. All three triggers
+	// are OR'd together - whichever is hit first wins. Zero (default) disables time-based
+	// rotation, preserving purely size/doc-count-driven behavior.
+	RotationInterval time.Duration
+	// ResyncTempDir, if set, overrides where Resync builds its temporary copy of the index
+	// (by default a sibling directory of BlevePath). Use this to point the copy at a different,
+	// bigger volume when the original one is nearly full. If the final location ends up on a
+	// different device than the temp directory, Resync falls back to copying and removing the
+	// files instead of renaming them.
+	ResyncTempDir string
+	// BulkMode, when true, makes Resync build its temporary copy with scorch's unsafe-batch mode
+	// enabled (see Indexer.SetBulkMode), trading mid-load crash safety for significantly faster
+	// bulk writes. A clean completion is unaffected; a crash during the reindex requires
+	// restarting Resync from scratch. Zero/false (default) keeps the regular durability.
+	BulkMode bool
+	// DiskUsageScanInterval, if set, periodically recomputes TotalDiskUsage from scratch to
+	// correct any drift in the incrementally-maintained running total. Zero (default) disables
+	// the periodic scan; TotalDiskUsage is still kept up to date incrementally on flush,
+	// rotation and delete, and is always computed once when Open completes.
+	DiskUsageScanInterval time.Duration
+	// EagerMarshal, when true, makes InsertOne call codec.Marshal (and attach the source, if
+	// StoreSource is set) before enqueueing, so the insert buffer holds the compact marshalled
+	// form instead of the original object. This trades producer-side CPU for lower buffer memory
+	// under backpressure, and surfaces marshal errors to the caller immediately rather than
+	// silently dropping them in watchInserts. Zero/false (default) keeps the lazy behavior.
+	EagerMarshal bool
+	// MaxResultWindow caps offset+limit for FindMany and its variants, which return
+	// ErrResultWindowExceeded past it instead of letting a huge offset exhaust memory or hit an
+	// obscure bleve error. Defaults to DefaultMaxResultWindow; set to -1 to disable the guard for
+	// trusted internal callers that need deep pagination.
+	MaxResultWindow int
+	// FlushEveryN, if set, flushes the current batch once this many documents have been staged
+	// since the last flush, independently of BatchSize (the larger cap) and the timer. Use a
+	// small value to trade write throughput for tighter read-your-writes latency. Zero (default)
+	// preserves the existing behavior of only flushing on BatchSize or the timer.
+	FlushEveryN int
+	// SearchBreakerThreshold, if set, trips a circuit breaker around the search path after this
+	// many consecutive search failures: further searches short-circuit with
+	// Indexer.ErrSearchUnavailable for SearchBreakerCooldown instead of being attempted, so a
+	// struggling index doesn't pile up slow failing searches. Zero (default) disables the
+	// breaker. See Indexer.Health.
+	SearchBreakerThreshold int
+	// SearchBreakerCooldown is how long the search circuit breaker stays open once tripped.
+	// Defaults to DefaultSearchBreakerCooldown when SearchBreakerThreshold is set but this isn't.
+	SearchBreakerCooldown time.Duration
+	// SegmentPrefix, if set, is prepended to the base segment name Open derives from BlevePath,
+	// so every segment this indexer creates or discovers - the active one and every rotated
+	// "base.NNNN" - carries it (e.g. "tenant42-docs", "tenant42-docs.0001"). listIndexes and the
+	// renumbering logic need no changes: they already key off the (now-prefixed) base name. This
+	// is a fixed prefix rather than a free-form template so the existing "base.NNNN" scheme,
+	// already sortable and parseable, is never put at risk. Multiple tenants can then safely
+	// share one directory, and tenant-scoped backup/cleanup becomes a simple glob on the prefix.
+	// Empty (default) preserves the current unprefixed naming.
+	SegmentPrefix string
+	// ShedLoadWhenUnhealthy, when true, makes InsertOne reject with Indexer's ErrIndexerUnhealthy
+	// immediately while the write index is unhealthy (see Indexer.Metrics' WriteUnhealthy),
+	// instead of buffering the insert onto a channel it will likely just be dropped from once
+	// full. Gives producers a fast, explicit signal to back off or route elsewhere rather than
+	// silently losing data later. False (default) preserves the existing buffer-and-hope
+	// behavior.
+	ShedLoadWhenUnhealthy bool
+	// InsertBufferMode controls what InsertOne and DeleteOne do when the pending channel is
+	// saturated: InsertBufferModeDrop (default, empty string) silently drops the document as
+	// before, InsertBufferModeError drops it and returns Indexer.ErrIndexBufferFull so the
+	// caller can retry or apply backpressure, and InsertBufferModeBlock blocks the caller until
+	// the channel has room instead of dropping at all. In every mode, a drop increments the
+	// counter reported by Indexer.BufferFullCount so operators can detect it even when the
+	// caller ignores the returned error.
+	InsertBufferMode string
+	// FlushFailureThreshold, if set, makes flush() invoke the callback registered via
+	// Indexer.SetOnFlushFailure once this many consecutive batch-flush failures have occurred.
+	// Zero (default) disables the callback; LastError and the retry-on-next-tick behavior are
+	// unaffected either way.
+	FlushFailureThreshold int
+	// LazyOpenRotated, when true, makes Open only eagerly open the active write segment; every
+	// other rotated segment is wrapped in a lazy handle that opens the underlying bleve.Index on
+	// first use instead, trading a little first-search latency per segment for far fewer file
+	// handles/caches held open at startup when there are hundreds of rotated segments. False
+	// (default) preserves the existing eager-open-everything behavior.
+	LazyOpenRotated bool
+	// LazyOpenIdleTimeout, when LazyOpenRotated is set, closes a lazily-opened segment again
+	// once it has gone unused for this long, freeing its handle until the next search needs it.
+	// Zero (default) keeps a lazily-opened segment open indefinitely once first used.
+	LazyOpenIdleTimeout time.Duration
+	// FlushInterval is how often watchInserts flushes the current batch on a timer, independently
+	// of BatchSize/FlushEveryN. Defaults to 3s, preserving the previous hardcoded cadence.
+	FlushInterval time.Duration
+	// RetentionMaxAge, if set, starts a background routine from Open that periodically removes
+	// rotated segments whose newest RetentionField value is older than this. The active write
+	// segment is never considered, regardless of age. Zero (default) disables the routine.
+	RetentionMaxAge time.Duration
+	// RetentionField is the date field read to determine a segment's age for RetentionMaxAge.
+	// Defaults to TimeField when empty.
+	RetentionField string
+	// RetentionCheckInterval is how often the RetentionMaxAge routine re-checks segment ages.
+	// Defaults to DefaultRetentionCheckInterval when RetentionMaxAge is set but this isn't.
+	RetentionCheckInterval time.Duration
+	// ReadOnly, when true, opens every existing segment read-only (via bleve.OpenUsing with
+	// "read_only": true) and disables every write path: InsertOne/DeleteOne return
+	// ErrIndexerReadOnly and Flush has nothing to commit, Open skips the listIndexes renumbering pass and never creates a missing segment, and no
+	// rotation/retention/TTL-sweep goroutine is started. Meant for search-only replicas pointed
+	// at a shared or rsynced copy of a primary's index directory, so they can never race the
+	// primary's own rename-based renumbering or accidentally write to its files. False (default)
+	// preserves the regular read-write behavior.
+	ReadOnly bool
+	// IndexType selects the bleve index implementation new segments are created with (the
+	// indexType argument of bleve.NewUsing): IndexTypeScorch (default) or IndexTypeUpsidedown.
+	// Only affects segments created from now on - existing ones on disk keep opening with
+	// whatever implementation they were written with, since bleve.Open figures that out from the
+	// index's own metadata.
+	IndexType string
+	// KVStoreName selects the underlying key/value store new segments are created with (the
+	// kvstore argument of bleve.NewUsing): KVStoreBoltDB (default) or KVStoreGoLevelDB.
+	// IndexTypeUpsidedown additionally accepts KVStoreGTreap, an in-memory-only store useful for
+	// benchmarking but not for anything that must survive a restart. Not every (IndexType,
+	// KVStoreName) pairing is meaningful; see validateIndexKVPair, checked once up front by
+	// NewIndexer.
+	KVStoreName string
+	// DefaultSearchTimeout bounds FindMany when the caller's ctx carries no deadline of its own,
+	// so a pathological query across many rotated segments can't wedge a server goroutine
+	// indefinitely. Zero (default) applies no such bound, preserving the previous behavior of
+	// relying entirely on the caller's context.
+	DefaultSearchTimeout time.Duration
 }
 
+const DefaultFlushInterval = 3 * time.Second
+const DefaultRetentionCheckInterval = time.Hour
+
+const (
+	IndexTypeScorch     = "scorch"
+	IndexTypeUpsidedown = "upsidedown"
+)
+
+const (
+	KVStoreBoltDB    = "boltdb"
+	KVStoreGoLevelDB = "goleveldb"
+	KVStoreGTreap    = "gtreap"
+)
+
+const (
+	InsertBufferModeDrop  = "drop"
+	InsertBufferModeBlock = "block"
+	InsertBufferModeError = "error"
+)
+
+const (
+	DefaultTTLSweepBatchSize = 500
+	DefaultTTLSweepPause     = 200 * time.Millisecond
+)
+
+const (
+	DuplicatePolicyLastWins  = "lastWins"
+	DuplicatePolicyFirstWins = "firstWins"
+	DuplicatePolicyError     = "error"
+)
+
 func (b *BleveConfig) Open(ctx context.Context, dsn string) (dao.Conn, error) {
 	b.BlevePath = dsn
 	b.MappingName = "docs"
 	b.RotationSize = DefaultRotationSize
 	b.BatchSize = DefaultBatchSize
+	b.MaxResultWindow = DefaultMaxResultWindow
+	b.FlushInterval = DefaultFlushInterval
+	b.IndexType = IndexTypeScorch
+	b.KVStoreName = KVStoreBoltDB
 	if strings.Contains(dsn, "?") {
 		parts := strings.Split(dsn, "?")
 		b.BlevePath = parts[0]
@@ -61,6 +266,170 @@ func (b *BleveConfig) Open(ctx context.Context, dsn string) (dao.Conn, error) {
 			if mn := values.Get("mapping"); mn != "" {
 				b.MappingName = mn
 			}
+			if ss := values.Get("storeSource"); ss != "" {
+				if pss, e := strconv.ParseBool(ss); e == nil {
+					b.StoreSource = pss
+				}
+			}
+			if tf := values.Get("timeField"); tf != "" {
+				b.TimeField = tf
+			}
+			if ari := values.Get("aliasRefreshInterval"); ari != "" {
+				if d, e := time.ParseDuration(ari); e == nil {
+					b.AliasRefreshInterval = d
+				}
+			}
+			if hwm := values.Get("throttleHighWaterMark"); hwm != "" {
+				if phwm, e := strconv.Atoi(hwm); e == nil {
+					b.ThrottleHighWaterMark = phwm
+				}
+			}
+			if mb := values.Get("throttleMaxBlock"); mb != "" {
+				if d, e := time.ParseDuration(mb); e == nil {
+					b.ThrottleMaxBlock = d
+				}
+			}
+			if dp := values.Get("duplicatePolicy"); dp != "" {
+				b.DuplicatePolicy = dp
+			}
+			if rw := values.Get("rotationWarmup"); rw != "" {
+				if d, e := time.ParseDuration(rw); e == nil {
+					b.RotationWarmup = d
+				}
+			}
+			if tf := values.Get("ttlField"); tf != "" {
+				b.TTLField = tf
+			}
+			b.TTLSweepBatchSize = DefaultTTLSweepBatchSize
+			b.TTLSweepPause = DefaultTTLSweepPause
+			if tsi := values.Get("ttlSweepInterval"); tsi != "" {
+				if d, e := time.ParseDuration(tsi); e == nil {
+					b.TTLSweepInterval = d
+				}
+			}
+			if tbs := values.Get("ttlSweepBatchSize"); tbs != "" {
+				if ptbs, e := strconv.Atoi(tbs); e == nil {
+					b.TTLSweepBatchSize = ptbs
+				}
+			}
+			if tsp := values.Get("ttlSweepPause"); tsp != "" {
+				if d, e := time.ParseDuration(tsp); e == nil {
+					b.TTLSweepPause = d
+				}
+			}
+			if asc := values.Get("aliasSearchConcurrency"); asc != "" {
+				if pasc, e := strconv.Atoi(asc); e == nil {
+					b.AliasSearchConcurrency = pasc
+				}
+			}
+			if rdc := values.Get("rotationDocCount"); rdc != "" {
+				if prdc, e := strconv.ParseInt(rdc, 10, 64); e == nil {
+					b.RotationDocCount = prdc
+				}
+			}
+			if ri := values.Get("rotationInterval"); ri != "" {
+				if d, e := time.ParseDuration(ri); e == nil {
+					b.RotationInterval = d
+				}
+			}
+			if rtd := values.Get("resyncTempDir"); rtd != "" {
+				b.ResyncTempDir = rtd
+			}
+			if bm := values.Get("bulkMode"); bm != "" {
+				if pbm, e := strconv.ParseBool(bm); e == nil {
+					b.BulkMode = pbm
+				}
+			}
+			if dsi := values.Get("diskUsageScanInterval"); dsi != "" {
+				if d, e := time.ParseDuration(dsi); e == nil {
+					b.DiskUsageScanInterval = d
+				}
+			}
+			if em := values.Get("eagerMarshal"); em != "" {
+				if pem, e := strconv.ParseBool(em); e == nil {
+					b.EagerMarshal = pem
+				}
+			}
+			if mrw := values.Get("maxResultWindow"); mrw != "" {
+				if pmrw, e := strconv.Atoi(mrw); e == nil {
+					b.MaxResultWindow = pmrw
+				}
+			}
+			if fen := values.Get("flushEveryN"); fen != "" {
+				if pfen, e := strconv.Atoi(fen); e == nil {
+					b.FlushEveryN = pfen
+				}
+			}
+			if sbt := values.Get("searchBreakerThreshold"); sbt != "" {
+				if psbt, e := strconv.Atoi(sbt); e == nil {
+					b.SearchBreakerThreshold = psbt
+				}
+			}
+			if sbc := values.Get("searchBreakerCooldown"); sbc != "" {
+				if d, e := time.ParseDuration(sbc); e == nil {
+					b.SearchBreakerCooldown = d
+				}
+			}
+			if sp := values.Get("segmentPrefix"); sp != "" {
+				b.SegmentPrefix = sp
+			}
+			if slwu := values.Get("shedLoadWhenUnhealthy"); slwu != "" {
+				if pslwu, e := strconv.ParseBool(slwu); e == nil {
+					b.ShedLoadWhenUnhealthy = pslwu
+				}
+			}
+			if ibm := values.Get("insertBufferMode"); ibm != "" {
+				b.InsertBufferMode = ibm
+			}
+			if fft := values.Get("flushFailureThreshold"); fft != "" {
+				if pfft, e := strconv.Atoi(fft); e == nil {
+					b.FlushFailureThreshold = pfft
+				}
+			}
+			if lor := values.Get("lazyOpenRotated"); lor != "" {
+				if plor, e := strconv.ParseBool(lor); e == nil {
+					b.LazyOpenRotated = plor
+				}
+			}
+			if loit := values.Get("lazyOpenIdleTimeout"); loit != "" {
+				if d, e := time.ParseDuration(loit); e == nil {
+					b.LazyOpenIdleTimeout = d
+				}
+			}
+			if fi := values.Get("flushInterval"); fi != "" {
+				if d, e := time.ParseDuration(fi); e == nil {
+					b.FlushInterval = d
+				}
+			}
+			if ra := values.Get("retentionMaxAge"); ra != "" {
+				if d, e := time.ParseDuration(ra); e == nil {
+					b.RetentionMaxAge = d
+				}
+			}
+			if rf := values.Get("retentionField"); rf != "" {
+				b.RetentionField = rf
+			}
+			if rci := values.Get("retentionCheckInterval"); rci != "" {
+				if d, e := time.ParseDuration(rci); e == nil {
+					b.RetentionCheckInterval = d
+				}
+			}
+			if it := values.Get("indexType"); it != "" {
+				b.IndexType = it
+			}
+			if kv := values.Get("kvStore"); kv != "" {
+				b.KVStoreName = kv
+			}
+			if ro := values.Get("readOnly"); ro != "" {
+				if pro, e := strconv.ParseBool(ro); e == nil {
+					b.ReadOnly = pro
+				}
+			}
+			if dst := values.Get("defaultSearchTimeout"); dst != "" {
+				if d, e := time.ParseDuration(dst); e == nil {
+					b.DefaultSearchTimeout = d
+				}
+			}
 		}
 	}
 	return b, nil
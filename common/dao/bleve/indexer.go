@@ -21,8 +21,15 @@
 package bleve
 
 import (
+	"archive/tar"
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -30,6 +37,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/bep/debounce"
@@ -37,14 +46,17 @@ import (
 	"github.com/blevesearch/bleve/v2/index/scorch"
 	"github.com/blevesearch/bleve/v2/index/upsidedown/store/boltdb"
 	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
 	"github.com/blevesearch/bleve/v2/search/query"
 	"github.com/rs/xid"
 
 	"github.com/pydio/cells/v4/common/dao"
+	"github.com/pydio/cells/v4/common/log"
 	"github.com/pydio/cells/v4/common/registry"
 	"github.com/pydio/cells/v4/common/registry/util"
 	"github.com/pydio/cells/v4/common/service/metrics"
 	"github.com/pydio/cells/v4/common/utils/configx"
+	json "github.com/pydio/cells/v4/common/utils/jsonx"
 	"github.com/pydio/cells/v4/common/utils/uuid"
 )
 
@@ -55,35 +67,344 @@ const (
 
 var (
 	UnitTestEnv = false
+	// ErrResultWindowExceeded is returned by FindMany and its variants when offset+limit surpasses
+	// the configured MaxResultWindow, instead of letting a huge offset exhaust memory or hit an
+	// obscure bleve error. Callers hitting this should switch to the scroll/search_after API.
+	ErrResultWindowExceeded = fmt.Errorf("offset+limit exceeds the configured MaxResultWindow, use a scroll/search_after based approach for deep pagination")
+	// ErrSearchUnavailable is returned by the search path while the circuit breaker is open
+	// (see SearchBreakerThreshold), instead of attempting a search that is likely to fail too.
+	ErrSearchUnavailable = fmt.Errorf("bleve: search circuit breaker is open, index is unhealthy")
+	// ErrIndexBufferFull is returned by InsertOne and DeleteOne when InsertBufferMode is set to
+	// InsertBufferModeError and the pending channel is saturated, instead of silently dropping
+	// the document. See Indexer.BufferFullCount for a counter-based signal that doesn't require
+	// the caller to check this error.
+	ErrIndexBufferFull = fmt.Errorf("bleve: insert/delete buffer is full")
+	// ErrIndexerUnhealthy is returned by InsertOne when ShedLoadWhenUnhealthy is enabled and the
+	// write index is unhealthy (see writeUnhealthy), instead of buffering an insert that would
+	// likely just be dropped later anyway.
+	ErrIndexerUnhealthy = fmt.Errorf("bleve: write index is unhealthy, rejecting insert")
+	// ErrIndexerReadOnly is returned by InsertOne and DeleteOne when BleveConfig.ReadOnly is set,
+	// instead of buffering a write that would never be flushed to the (read-only-opened) segment.
+	ErrIndexerReadOnly = fmt.Errorf("bleve: indexer is read-only, rejecting write")
 )
 
+// DefaultMaxResultWindow is the MaxResultWindow applied when a BleveConfig does not set one.
+const DefaultMaxResultWindow = 10000
+
+// DefaultSearchBreakerCooldown is the cooldown applied when SearchBreakerThreshold is set but
+// SearchBreakerCooldown is not.
+const DefaultSearchBreakerCooldown = 30 * time.Second
+
+// DefaultHealthyStallThreshold is how stale watchInserts' heartbeat may be before Healthy
+// considers the flush goroutine stalled rather than merely idle.
+const DefaultHealthyStallThreshold = 30 * time.Second
+
+// checkResultWindow enforces MaxResultWindow (see ErrResultWindowExceeded), unless disabled by
+// setting MaxResultWindow to -1.
+func checkResultWindow(bc *BleveConfig, offset, limit int32) error {
+	if bc.MaxResultWindow < 0 {
+		return nil
+	}
+	if int64(offset)+int64(limit) > int64(bc.MaxResultWindow) {
+		return ErrResultWindowExceeded
+	}
+	return nil
+}
+
 type IndexDAO interface {
 	DAO
 	dao.IndexDAO
 }
 
+// attachHighlightFragments hands a hit's highlighted fragments, if any, to result when it
+// implements dao.HighlightReceiver. It is a no-op when the hit carried no fragments (highlighting
+// was not requested, or matched nothing to highlight) or the codec's result type doesn't
+// implement the receiver interface.
+func attachHighlightFragments(result interface{}, hit *search.DocumentMatch) {
+	if len(hit.Fragments) == 0 {
+		return
+	}
+	if receiver, ok := result.(dao.HighlightReceiver); ok {
+		receiver.SetHighlightFragments(hit.Fragments)
+	}
+}
+
 // Indexer is the syslog specific implementation of the Log server
 type Indexer struct {
 	DAO
+	// segLock guards searchIndex, indexes and cursor against the data race between rotation
+	// (which appends/replaces them from the flush goroutine) and every other goroutine that
+	// reads them - FindMany, getWriteIndex, Stats, SearchTimeRange, the TTL/retention sweeps.
+	// Mutators take segLock.Lock() only around the slice/cursor/alias update itself; readers
+	// take segLock.RLock() just long enough to copy what they need, then release it before
+	// running the actual (potentially slow) bleve search or write. It is orthogonal to
+	// flushLock, which keeps serializing the write path and the segment-management operations
+	// (rotateIfNeeded, Merge, RestoreSegment, sweepOldSegments...) against each other; segLock
+	// only needs to be held for the brief moment those operations touch these three fields.
+	segLock     sync.RWMutex
 	searchIndex bleve.IndexAlias
 	indexes     []bleve.Index
 	cursor      int
 	indexPath   string
 
-	opened      bool
-	inserts     chan interface{}
-	deletes     chan interface{}
-	forceFlush  chan bool
+	// openLock guards opened against the race between Close flipping it to false and a concurrent
+	// InsertOne/DeleteOne checking it and then sending on s.inserts: both the check and the send
+	// happen while holding openLock (RLock), and Close holds the write side while it flips opened
+	// and signals shutdown, so no sender can straddle the transition. See isOpen and the send
+	// blocks in InsertOne/DeleteOne.
+	openLock sync.RWMutex
+	opened   bool
+	// inserts carries both insertEnvelope inserts and deletes (tagged via insertEnvelope.delete),
+	// a single channel rather than a separate "deletes" one so watchInserts processes them in
+	// the exact order InsertOne/DeleteOne enqueued them: with two channels, Go's select would
+	// pick whichever is ready in no particular order, so a delete sent just before a re-insert of
+	// the same ID could still be applied after it depending on scheduling.
+	inserts    chan interface{}
+	forceFlush chan bool
+	// flushSync carries done channels for FlushSync: watchInserts receives one, performs a flush,
+	// and sends the resulting error (if any) back on it before moving on.
+	flushSync   chan chan error
 	insertsDone chan bool
-	crtBatch    *bleve.Batch
-	flushLock   *sync.Mutex
+	// closeOnce guards the insertsDone/forceFlush close in Close() against firing twice, which
+	// would otherwise panic if Close raced with itself. Reset alongside insertsDone/closedDone
+	// whenever Open recreates them, so an Indexer that is Closed then reopened can be Closed again.
+	closeOnce sync.Once
+	// closedDone is closed by watchInserts right after it closes searchIndex and every segment
+	// in s.indexes, just before the goroutine returns. Close() waits on it so callers observe a
+	// fully-closed indexer deterministically, instead of the old fixed 5-second sleep Resync and
+	// Truncate used to guess at.
+	closedDone chan struct{}
+	crtBatch   *bleve.Batch
+	flushLock  *sync.Mutex
 
 	codec          dao.IndexCodex
 	serviceConfigs configx.Values
 
+	// logger, when set via SetLogger, receives the internal diagnostic lines the package would
+	// otherwise print directly to stdout via fmt.Println. Nil (the default) preserves that
+	// original behavior, so existing deployments keep seeing these lines unless they opt in.
+	logger func(msg string)
+
 	statusInput chan map[string]interface{}
 	debouncer   func(func())
 	metricsName string
+
+	// writeUnhealthy is set when the write index keeps failing even after a rotation-recovery attempt
+	writeUnhealthy bool
+
+	// bulkBatchSize and disableTimerFlush override the regular flush cadence for bulk-load
+	// operations (e.g. the dup indexer used by Resync), to reduce IO contention from
+	// concurrent flushing while a large reindex is in progress. See SetBulkFlush.
+	bulkBatchSize     int
+	disableTimerFlush bool
+
+	// bulkMode enables scorch's unsafe-batch mode on indexes created while it is set. See
+	// SetBulkMode.
+	bulkMode bool
+
+	// storeConfig is merged into the kvConfig map passed to bleve.NewUsing for indexes created
+	// while it is set, letting a caller tune whatever the underlying store (scorch/boltdb by
+	// default) accepts there - e.g. "bolt_timeout" or "numSnapshotsToKeep". See SetStoreConfig.
+	storeConfig map[string]interface{}
+
+	// nameForRotation and segmentNameMatcher replace the default zero-padded "<base>.NNNN" segment
+	// naming/discovery scheme. nil (the default for both) keeps the built-in scheme. See
+	// SetNameForRotation.
+	nameForRotation    func(base string, seq int, t time.Time) string
+	segmentNameMatcher func(curBase, base string) bool
+
+	// shadowIndexes holds secondary indexes added via AddShadowIndex, keyed by name, used for
+	// A/B testing an alternate mapping/analyzer against live data without committing to it. They
+	// receive a copy of every insert (see teeToShadows) but are only ever queried explicitly
+	// through SearchShadow - FindMany never reads them.
+	shadowLock    sync.Mutex
+	shadowIndexes map[string]bleve.Index
+
+	// diskUsageCache holds the last known on-disk size of each segment directory, keyed by
+	// path, so TotalDiskUsage() can report a running total without re-walking every segment on
+	// every call. It is kept up to date incrementally from flush/rotation/delete and corrected
+	// periodically by a full scan. See TotalDiskUsage.
+	diskUsageCache     map[string]int64
+	diskUsageCacheLock sync.Mutex
+	totalDiskUsage     int64
+
+	// aliasLock guards pendingAlias, used when AliasRefreshInterval postpones adding newly
+	// rotated segments to the search alias.
+	aliasLock    sync.Mutex
+	pendingAlias []bleve.Index
+
+	// batchCtx is the context carried by the most recent insert/delete staged into crtBatch,
+	// so flush failures can be logged/traced against the request that triggered them.
+	batchCtx context.Context
+
+	// nilMarshalCount counts documents skipped because codec.Marshal returned (nil, nil).
+	nilMarshalCount uint64
+
+	// bufferFullCount counts documents dropped by InsertOne or DeleteOne because the pending
+	// channel was saturated, regardless of InsertBufferMode (including InsertBufferModeError,
+	// where the caller also gets ErrIndexBufferFull back). Lets operators notice backpressure
+	// even when callers ignore the returned error.
+	bufferFullCount uint64
+
+	// insertCount counts documents received by InsertOne past its open/read-only/load-shedding
+	// checks, including those later dropped for a full buffer (those are also counted by
+	// bufferFullCount). flushCount and flushErrorCount count successful and failed flush() calls respectively.
+	// rotationCount counts segment rotations performed by rotateIfNeeded/forceRotate. These back
+	// the ingestion/flush operational metrics exposed via Metrics().
+	insertCount     uint64
+	flushCount      uint64
+	flushErrorCount uint64
+	rotationCount   uint64
+
+	// searchCount and searchNanos accumulate the number of searches run through searchAlias and
+	// their total latency, so Metrics() can report both a count and an average latency.
+	searchCount uint64
+	searchNanos uint64
+
+	// crtBatchIDs tracks the IDs already staged into crtBatch, to apply DuplicatePolicy. It is
+	// reset alongside crtBatch whenever a batch is flushed.
+	crtBatchIDs map[string]bool
+
+	// duplicateCount counts documents dropped within a batch because of DuplicatePolicyFirstWins
+	// or DuplicatePolicyError.
+	duplicateCount uint64
+
+	// openedAt records when Open completed, to defer the rotation-size check for RotationWarmup.
+	openedAt time.Time
+
+	// writeIndexStrategy picks which of s.indexes to write to, given the current segment list
+	// and the cursor (newest segment). It defaults to newestSegmentStrategy. See
+	// SetWriteIndexStrategy for the consistency implications of overriding it.
+	writeIndexStrategy WriteIndexStrategy
+
+	// lastTTLSweepCount is the number of documents deleted by the most recently completed TTL
+	// sweep. See watchTTLSweep.
+	lastTTLSweepCount int64
+
+	// searchWarnLock guards lastSearchWarning, set whenever a search returned partial results
+	// because one or more segments failed (e.g. a corrupt old segment), so callers can surface
+	// degraded availability instead of it passing silently.
+	searchWarnLock    sync.Mutex
+	lastSearchWarning string
+
+	// writeSegmentDocCount is a cheap running count of documents staged into the active write
+	// segment since the last rotation, used by rotateIfNeeded's RotationDocCount check so it
+	// never has to call the (disk-backed) Index.DocCount() on every flush.
+	writeSegmentDocCount int64
+
+	// writeSegmentOpenedAt records when the active write segment became the write target (when
+	// Open completed, even for a segment that already existed on disk, or when a rotation opened a
+	// new one), used by rotateIfNeeded's RotationInterval check. Reset alongside
+	// writeSegmentDocCount whenever a new write segment is opened.
+	writeSegmentOpenedAt time.Time
+
+	// lastFlushErrLock guards lastFlushErr, the most recent error returned while flushing a
+	// batch, retained rather than discarded so LastError can report it and so flush() can keep
+	// the failed batch around for a retry on the next tick instead of dropping it.
+	lastFlushErrLock sync.Mutex
+	lastFlushErr     error
+
+	// consecutiveFlushFailures counts flush() failures since the last success, used to trigger
+	// onFlushFailure once FlushFailureThreshold is reached. Reset to 0 on the next successful
+	// flush.
+	consecutiveFlushFailures int32
+
+	// lastWatchHeartbeat is the UnixNano timestamp of watchInserts' most recent loop iteration,
+	// updated every time around regardless of which select case fired - the periodic ticker
+	// guarantees at least one update every flushInterval even when idle. Healthy uses how stale
+	// this is to tell a genuinely wedged goroutine (stuck inside flush, say) apart from one that
+	// is simply idle.
+	lastWatchHeartbeat int64
+
+	// onFlushFailure, if set via SetOnFlushFailure, is called once consecutiveFlushFailures
+	// reaches BleveConfig.FlushFailureThreshold, so the embedding service can alarm on
+	// persistent flush failures (e.g. a full disk) instead of discovering the data loss later.
+	onFlushFailure func(err error, consecutiveFailures int)
+
+	// onCorruptSegment, if set via SetOnCorruptSegment, is called whenever Open quarantines a
+	// segment it could not open, so the embedding service can alarm on data loss instead of only
+	// seeing it in the logs.
+	onCorruptSegment func(path string, cause error)
+
+	// onRotate, if set via SetOnRotate, is called from rotateIfNeeded right after a new write
+	// segment is opened, with the path of the segment that was just closed off from further
+	// writes and the path of the new one - e.g. to trigger an external backup of the former.
+	onRotate func(closedPath, newPath string)
+
+	// onFlush, if set via SetOnFlush, is called from flush after a batch is successfully written,
+	// with the number of operations it contained.
+	onFlush func(count int)
+
+	// progress, if set via SetProgress, receives structured Progress updates from Resync and
+	// Truncate alongside their string logger callback. See progress.go.
+	progress chan<- Progress
+
+	// stagedSinceFlush counts inserts/deletes staged into crtBatch since the last flush,
+	// guarded by flushLock like crtBatch itself. It backs FlushEveryN, a minimum-latency knob
+	// distinct from BatchSize (the cap) and the timer.
+	stagedSinceFlush int
+
+	// searchBreakerLock guards the search circuit breaker state. searchBreakerFailures counts
+	// consecutive search failures; once it reaches SearchBreakerThreshold, searchAlias
+	// short-circuits with ErrSearchUnavailable until searchBreakerOpenUntil. See Health.
+	searchBreakerLock      sync.Mutex
+	searchBreakerFailures  int
+	searchBreakerOpenUntil time.Time
+
+	// rotationPaused suspends rotateIfNeeded while non-zero. See PauseRotation/ResumeRotation.
+	rotationPaused int32
+
+	// manifestLock guards reads/writes of the on-disk segment manifest (see manifest.go), which
+	// records each segment's creation time and rotation reason alongside the order listIndexes
+	// already derives from directory names.
+	manifestLock sync.Mutex
+
+	// SynchronousMode, when true, makes InsertOne marshal and index the document inline on the
+	// caller's goroutine instead of handing it off to the background inserts channel/batch. It
+	// is test-only: it trades all batching/throttling benefits for immediate visibility, so that
+	// tests can assert right after InsertOne returns with no Flush or sleep. Unlike UnitTestEnv,
+	// which still routes through watchInserts with a blocking channel, this bypasses it entirely.
+	SynchronousMode bool
+}
+
+// unwrapEnvelope extracts the payload and originating context out of a channel item. Items
+// sent through InsertOne/DeleteOne are wrapped as insertEnvelope; items sent directly onto
+// the channel (e.g. by Resync) may still be raw payloads with no associated context.
+func (s *Indexer) unwrapEnvelope(item interface{}) (interface{}, context.Context) {
+	if env, ok := item.(insertEnvelope); ok {
+		return env.data, env.ctx
+	}
+	return item, context.Background()
+}
+
+// segmentSnapshot is a consistent, point-in-time copy of searchIndex/indexes/cursor, taken under
+// segLock.RLock() so callers can run a search or other read-only work against it without holding
+// the lock for the duration.
+type segmentSnapshot struct {
+	searchIndex bleve.IndexAlias
+	indexes     []bleve.Index
+	cursor      int
+}
+
+// snapshotSegments copies searchIndex, indexes and cursor under segLock.RLock(). The returned
+// indexes slice is a fresh copy, safe to range over after segLock is released even while a
+// rotation appends to the live s.indexes concurrently.
+func (s *Indexer) snapshotSegments() segmentSnapshot {
+	s.segLock.RLock()
+	defer s.segLock.RUnlock()
+	indexes := make([]bleve.Index, len(s.indexes))
+	copy(indexes, s.indexes)
+	return segmentSnapshot{searchIndex: s.searchIndex, indexes: indexes, cursor: s.cursor}
+}
+
+// setSegments replaces searchIndex, indexes and cursor atomically under segLock.Lock(). Callers
+// that only need to update one of the three pass the others unchanged.
+func (s *Indexer) setSegments(alias bleve.IndexAlias, indexes []bleve.Index, cursor int) {
+	s.segLock.Lock()
+	s.searchIndex = alias
+	s.indexes = indexes
+	s.cursor = cursor
+	s.segLock.Unlock()
 }
 
 // NewIndexer creates and configures a default Bleve instance to store technical logs
@@ -98,12 +419,72 @@ func NewIndexer(ctx context.Context, rd dao.DAO) (dao.IndexDAO, error) {
 	if conf.RotationSize > -1 && conf.RotationSize < MinRotationSize {
 		return nil, fmt.Errorf("use a rotation size bigger than %d", MinRotationSize)
 	}
+	if conf.BlevePath == "" && conf.RotationSize > -1 {
+		// In-memory indexes have no disk usage to measure, so rotateIfNeeded always no-ops for
+		// them: a non-default RotationSize here would silently never take effect.
+		return nil, fmt.Errorf("rotationSize cannot be used with an in-memory (empty path) bleve index, disable rotation by setting rotationSize=-1")
+	}
+	if conf.BatchSize <= 0 {
+		return nil, fmt.Errorf("batchSize must be strictly positive, got %d", conf.BatchSize)
+	}
+	if er := validateIndexKVPair(conf.IndexType, conf.KVStoreName); er != nil {
+		return nil, er
+	}
 	server := &Indexer{
 		DAO: d,
 	}
 	return server, nil
 }
 
+// validateIndexKVPair checks that indexType/kvStore is a combination openOneIndex actually knows
+// how to build with bleve.NewUsing, so a typo or an unsupported pairing is reported up front at
+// NewIndexer time rather than surfacing later as an opaque error from bleve itself the first time
+// a segment needs creating.
+func validateIndexKVPair(indexType, kvStore string) error {
+	switch indexType {
+	case IndexTypeScorch:
+		switch kvStore {
+		case KVStoreBoltDB, KVStoreGoLevelDB:
+			return nil
+		}
+	case IndexTypeUpsidedown:
+		switch kvStore {
+		case KVStoreBoltDB, KVStoreGoLevelDB, KVStoreGTreap:
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported bleve indexType %q, use %q or %q", indexType, IndexTypeScorch, IndexTypeUpsidedown)
+	}
+	return fmt.Errorf("unsupported bleve kvStore %q for indexType %q", kvStore, indexType)
+}
+
+// NewIndexerFromIndex wraps a pre-built bleve.Index (or alias) into an Indexer, wiring up the
+// insert/flush/search pipeline without any on-disk segment management: since there is no
+// indexPath, rotation never triggers and Resync/Truncate are no-ops. Useful for unit tests and
+// in-process caches where the caller already owns a configured index.
+func NewIndexerFromIndex(idx bleve.Index, codec dao.IndexCodex) (dao.IndexDAO, error) {
+	s := &Indexer{
+		DAO:   &Handler{DAO: dao.AbstractDAO(&BleveConfig{MappingName: "docs"}, Driver, "", "")},
+		codec: codec,
+	}
+	s.setSegments(bleve.NewIndexAlias(idx), []bleve.Index{idx}, 0)
+	s.flushLock = &sync.Mutex{}
+	s.forceFlush = make(chan bool, 1)
+	s.flushSync = make(chan chan error, 1)
+	if UnitTestEnv {
+		s.inserts = make(chan interface{})
+	} else {
+		s.inserts = make(chan interface{}, BufferedChanSize)
+	}
+	s.insertsDone = make(chan bool)
+	s.closedDone = make(chan struct{})
+	s.opened = true
+	s.openedAt = time.Now()
+	s.writeSegmentOpenedAt = s.openedAt
+	go s.watchInserts()
+	return s, nil
+}
+
 func (s *Indexer) Init(ctx context.Context, cfg configx.Values) error {
 	if er := s.DAO.Init(ctx, cfg); er != nil {
 		return er
@@ -172,86 +553,401 @@ func (s *Indexer) Stats() map[string]interface{} {
 	m := map[string]interface{}{
 		"indexes": s.listIndexes(),
 	}
-	if count, e := s.searchIndex.DocCount(); e == nil {
+	alias := s.snapshotSegments().searchIndex
+	if count, e := alias.DocCount(); e == nil {
 		m["docsCount"] = count
 	}
+	if _, perIndex, e := s.docCounts(); e == nil {
+		m["docsCountPerIndex"] = perIndex
+	}
 	return m
 }
 
+// DocCount returns the total number of documents currently indexed, by summing index.DocCount()
+// across every rotated segment. Unlike Count(ctx, matchAllQuery, nil), it never runs a search, so
+// it's cheap enough to call on a hot path such as deciding whether to rotate by document count
+// instead of size.
+func (s *Indexer) DocCount() (uint64, error) {
+	total, _, err := s.docCounts()
+	return total, err
+}
+
+// Healthy is a cheap liveness/readiness check meant for an orchestrator probe: it reports an
+// error as soon as the indexer is not opened, a DocCount() against every segment fails (the write
+// index not responding to even a trivial operation), or watchInserts' heartbeat has gone stale
+// for longer than DefaultHealthyStallThreshold, meaning the goroutine is wedged rather than just
+// idle waiting for the next insert or ticker. A nil return means none of that was observed.
+func (s *Indexer) Healthy() error {
+	if !s.isOpen() {
+		return fmt.Errorf("bleve: indexer is not opened")
+	}
+	if _, err := s.DocCount(); err != nil {
+		return fmt.Errorf("bleve: write index is not responding: %w", err)
+	}
+	if last := atomic.LoadInt64(&s.lastWatchHeartbeat); last > 0 {
+		if age := time.Since(time.Unix(0, last)); age > DefaultHealthyStallThreshold {
+			return fmt.Errorf("bleve: flush goroutine stalled for %s", age.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// docCounts is the shared implementation behind DocCount and Stats' "docsCountPerIndex" entry. It
+// takes a single snapshotSegments() so the total and the per-index breakdown always agree, even if
+// a rotation happens to land between them.
+func (s *Indexer) docCounts() (total uint64, perIndex map[string]uint64, err error) {
+	snap := s.snapshotSegments()
+	perIndex = make(map[string]uint64, len(snap.indexes))
+	for _, idx := range snap.indexes {
+		c, e := idx.DocCount()
+		if e != nil {
+			return 0, nil, e
+		}
+		perIndex[filepath.Base(idx.Name())] = c
+		total += c
+	}
+	return total, perIndex, nil
+}
+
 // Open lists all existing indexes and creates a writeable index on the active one
 // and a composed index for searching. It calls watchInserts() to start watching for
 // new logs
 func (s *Indexer) Open(c context.Context, indexPath string) error {
 
+	if s.isOpen() {
+		// A previous Open was never followed by a Close: close it first rather than
+		// overwriting s.inserts/s.indexes under the still-running watchInserts goroutine.
+		s.logPrintln("[pydio.grpc.log] Open called on an already-opened indexer, closing previous instance first")
+		if er := s.Close(c); er != nil {
+			return fmt.Errorf("cannot close previously opened indexer: %s", er.Error())
+		}
+	}
+
 	s.indexPath = indexPath
+	if prefix := s.MustBleveConfig(c).SegmentPrefix; prefix != "" {
+		if strings.ContainsRune(prefix, filepath.Separator) {
+			return fmt.Errorf("bleve: segmentPrefix %q must not contain a path separator", prefix)
+		}
+		dir, base := filepath.Split(s.indexPath)
+		s.indexPath = filepath.Join(dir, prefix+base)
+	}
+	s.openedAt = time.Now()
+	s.writeSegmentOpenedAt = s.openedAt
 	mappingName := s.MustBleveConfig(c).MappingName
 
-	s.searchIndex = bleve.NewIndexAlias()
-	s.indexes = []bleve.Index{}
+	alias := bleve.NewIndexAlias()
+	var indexes []bleve.Index
+	var cursor int
 	s.flushLock = &sync.Mutex{}
 	s.forceFlush = make(chan bool, 1)
+	s.flushSync = make(chan chan error, 1)
 	if UnitTestEnv {
 		s.inserts = make(chan interface{})
-		s.deletes = make(chan interface{})
 	} else {
 		s.inserts = make(chan interface{}, BufferedChanSize)
-		s.deletes = make(chan interface{}, BufferedChanSize)
 	}
 
-	existing := s.listIndexes(true)
+	bc := s.MustBleveConfig(c)
+	// In ReadOnly mode listIndexes must never renumber/rename files out from under the primary
+	// that actually owns this directory, and a missing segment is an error rather than something
+	// this indexer is allowed to create. Recovering a renumbering interrupted by a previous crash
+	// is itself a rename, so it is skipped for the same reason.
+	if !bc.ReadOnly {
+		s.recoverInterruptedRenumbering()
+	}
+	existing := s.listIndexes(!bc.ReadOnly)
 	if len(existing) == 0 {
+		if bc.ReadOnly {
+			return fmt.Errorf("bleve: no segment found at %q, a ReadOnly indexer cannot create one", s.indexPath)
+		}
 		index, err := s.openOneIndex(s.indexPath, mappingName)
 		if err != nil {
 			return err
 		}
-		s.searchIndex.Add(index)
-		s.indexes = append(s.indexes, index)
-		s.cursor = 0
+		alias.Add(index)
+		indexes = append(indexes, index)
+		cursor = 0
+		existing = []string{filepath.Base(s.indexPath)}
+		if !bc.ReadOnly {
+			s.recordManifestSegment(s.indexPath, "initial")
+			s.recordMappingVersion(fingerprintMapping(index))
+		}
 	} else {
-		for _, iName := range existing {
+		// listIndexes returns the active write segment last: open it eagerly, and - when
+		// LazyOpenRotated is set - defer opening every older, rotated segment until a search
+		// actually needs it. See lazyIndex.
+		for n, iName := range existing {
 			iPath := filepath.Join(filepath.Dir(s.indexPath), iName)
+			if bc.LazyOpenRotated && n < len(existing)-1 {
+				indexes = append(indexes, newLazyIndex(iPath, mappingName, s.openOneIndex, bc.LazyOpenIdleTimeout))
+				continue
+			}
 			if index, err := s.openOneIndex(iPath, mappingName); err == nil {
-				s.indexes = append(s.indexes, index)
+				indexes = append(indexes, index)
 			} else {
-				fmt.Println("[pydio.grpc.log] Cannot open bleve index", iPath, err)
+				s.logPrintln("[pydio.grpc.log] Cannot open bleve index", iPath, err)
+				if bc.ReadOnly {
+					// quarantineSegment renames the offending directory, which is itself a
+					// write this mode must never perform against a shared/primary-owned path.
+					continue
+				}
+				s.quarantineSegment(iPath, err)
 			}
 		}
-		s.searchIndex.Add(s.indexes...)
-		s.cursor = len(s.indexes) - 1
+		alias.Add(indexes...)
+		cursor = len(indexes) - 1
 	}
+	if !bc.ReadOnly {
+		s.reconcileManifest(existing)
+	}
+	s.setSegments(alias, indexes, cursor)
 	s.insertsDone = make(chan bool)
+	s.closedDone = make(chan struct{})
+	s.closeOnce = sync.Once{}
+	s.openLock.Lock()
 	s.opened = true
+	s.openLock.Unlock()
 
-	if s.indexPath != "" && s.MustBleveConfig(c).RotationSize > -1 {
-		s.rotateIfNeeded()
+	if !bc.ReadOnly {
+		if s.indexPath != "" && bc.RotationSize > -1 {
+			s.rotateIfNeeded()
+		}
 	}
+	// watchInserts still runs even in ReadOnly mode: Close relies on it to close searchIndex and
+	// every segment deterministically. It never does any actual writing here, since
+	// InsertOne/DeleteOne refuse before ever reaching s.inserts and flush() is a no-op while
+	// crtBatch is nil.
 	go s.watchInserts()
+	if !bc.ReadOnly {
+		if ari := bc.AliasRefreshInterval; ari > 0 {
+			go s.watchAliasRefresh(ari)
+		}
+		if bc.TTLField != "" && bc.TTLSweepInterval > 0 {
+			go s.watchTTLSweep(bc.TTLSweepInterval)
+		}
+		if bc.RetentionMaxAge > 0 {
+			interval := bc.RetentionCheckInterval
+			if interval <= 0 {
+				interval = DefaultRetentionCheckInterval
+			}
+			go s.watchRetention(interval)
+		}
+	}
+	s.refreshTotalDiskUsage()
+	if dsi := s.MustBleveConfig(c).DiskUsageScanInterval; dsi > 0 {
+		go s.watchDiskUsage(dsi)
+	}
+	if !bc.ReadOnly {
+		if mismatch, err := s.MappingVersionMismatch(); err == nil && mismatch {
+			s.logPrintln("[pydio.grpc.log] Indexer mapping has changed since these segments were created, call MigrateMapping to reindex")
+		}
+	}
 	return nil
 }
 
+// isOpen reports whether the indexer is open. It takes openLock's read side, the same lock
+// InsertOne/DeleteOne hold across their own opened-check and channel send, so Close can never
+// flip opened and signal shutdown in the middle of one of those sends.
+func (s *Indexer) isOpen() bool {
+	s.openLock.RLock()
+	defer s.openLock.RUnlock()
+	return s.opened
+}
+
+// Close stops the indexer gracefully: it signals watchInserts to shut down rather than closing
+// s.inserts itself, so an InsertOne/DeleteOne call racing with Close sends into a channel that
+// stays open (and gets drained, see watchInserts) instead of panicking on a send to a closed
+// channel. closeOnce makes the signal idempotent against a concurrent or repeated Close call.
+// Flipping opened and signalling shutdown happen under openLock's write side, so a concurrent
+// InsertOne/DeleteOne either completes its send against a still-fully-open indexer beforehand, or
+// observes opened already false and returns without sending at all - never both at once.
 func (s *Indexer) Close(ctx context.Context) error {
+	s.openLock.Lock()
 	if !s.opened {
+		s.openLock.Unlock()
 		return nil
 	}
 	s.opened = false
-	close(s.insertsDone)
-	close(s.inserts)
-	close(s.deletes)
-	close(s.forceFlush)
+	s.closeOnce.Do(func() {
+		close(s.insertsDone)
+		close(s.forceFlush)
+	})
+	s.openLock.Unlock()
+	<-s.closedDone // deterministically wait for watchInserts to drain s.inserts, flush, and close every segment
+	s.shadowLock.Lock()
+	for _, idx := range s.shadowIndexes {
+		idx.Close()
+	}
+	s.shadowLock.Unlock()
+	return nil
+}
+
+// insertEnvelope carries the originating context alongside a channel payload so tracing spans
+// started around InsertOne/DeleteOne are not lost by the time watchInserts processes them.
+type insertEnvelope struct {
+	ctx  context.Context
+	data interface{}
+	// marshalled is true when data has already gone through codec.Marshal (and StoreSource, if
+	// enabled) in InsertOne, before being enqueued. See EagerMarshal.
+	marshalled bool
+	// delete is true when this envelope was enqueued by DeleteOne rather than InsertOne, in
+	// which case data is the document ID (a string) to remove from crtBatch instead of a
+	// document to marshal and index. See the comment on Indexer.inserts for why deletes share
+	// this channel instead of a separate one.
+	delete bool
+}
+
+// Reload re-scans the segment directory and swaps in whatever it finds - new rotated segments an
+// out-of-band process (e.g. an rsync from a primary onto a ReadOnly search replica) has added
+// since Open last ran, and size growth in the active one. A segment already held open keeps its
+// existing handle (bleve/bolt reads are transactional, so growth in the underlying file is simply
+// visible on the next search through it; reopening a segment that is already open - especially the
+// active write segment - would block forever on bolt's file lock), and only genuinely new segment
+// paths are opened. The alias built from the result is only swapped into s.searchIndex/s.indexes
+// atomically, under segLock via setSegments, once every one of them is ready: in-flight searches
+// always see either the old or the new alias in full, never a partial or empty one. Segment
+// handles no longer referenced after the swap (e.g. one the primary removed) are closed right
+// after, the same way removeRotatedSegment closes a retired segment.
+func (s *Indexer) Reload(ctx context.Context) error {
+	if s.indexPath == "" {
+		return fmt.Errorf("bleve: cannot Reload an in-memory indexer")
+	}
+	if !s.isOpen() {
+		return fmt.Errorf("bleve: Reload called on a closed indexer")
+	}
+	bc := s.MustBleveConfig(ctx)
+	mappingName := bc.MappingName
+
+	existing := s.listIndexes(!bc.ReadOnly)
+	if len(existing) == 0 {
+		return fmt.Errorf("bleve: no segment found at %q", s.indexPath)
+	}
+
+	prev := s.snapshotSegments()
+	prevByName := make(map[string]bleve.Index, len(prev.indexes))
+	for _, idx := range prev.indexes {
+		prevByName[filepath.Base(idx.Name())] = idx
+	}
+
+	var indexes []bleve.Index
+	for n, iName := range existing {
+		if idx, ok := prevByName[iName]; ok {
+			indexes = append(indexes, idx)
+			delete(prevByName, iName)
+			continue
+		}
+		iPath := filepath.Join(filepath.Dir(s.indexPath), iName)
+		if bc.LazyOpenRotated && n < len(existing)-1 {
+			indexes = append(indexes, newLazyIndex(iPath, mappingName, s.openOneIndex, bc.LazyOpenIdleTimeout))
+			continue
+		}
+		index, err := s.openOneIndex(iPath, mappingName)
+		if err != nil {
+			s.logPrintln("[pydio.grpc.log] Reload: cannot open bleve index", iPath, err)
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	if len(indexes) == 0 {
+		return fmt.Errorf("bleve: Reload could not open any segment at %q", s.indexPath)
+	}
+
+	alias := bleve.NewIndexAlias()
+	alias.Add(indexes...)
+	cursor := len(indexes) - 1
+	s.setSegments(alias, indexes, cursor)
+
+	// Whatever is left in prevByName is no longer referenced by the new alias/indexes - its segment
+	// was no longer found on disk, since handles still listed there were reused above - and safe to
+	// close now.
+	for _, idx := range prevByName {
+		if err := idx.Close(); err != nil {
+			s.logPrintln("[pydio.grpc.log] Reload: error closing previous segment handle", idx.Name(), err)
+		}
+	}
+	if !bc.ReadOnly {
+		s.reconcileManifest(existing)
+	}
+	s.refreshTotalDiskUsage()
 	return nil
 }
 
 func (s *Indexer) InsertOne(ctx context.Context, data interface{}) error {
 
+	if !s.isOpen() {
+		return nil
+	}
+	bc := s.MustBleveConfig(ctx)
+	if bc.ReadOnly {
+		return ErrIndexerReadOnly
+	}
+	if bc.ShedLoadWhenUnhealthy && s.writeUnhealthy {
+		return ErrIndexerUnhealthy
+	}
+	atomic.AddUint64(&s.insertCount, 1)
+	if s.SynchronousMode {
+		return s.indexInline(ctx, data)
+	}
+	env := insertEnvelope{ctx: ctx, data: data}
+	if bc.EagerMarshal {
+		// Marshal now so the (potentially large) original object isn't retained in the insert
+		// buffer until watchInserts gets to it, trading producer-side CPU for lower buffer
+		// memory under backpressure. Marshal errors surface to the caller immediately instead
+		// of being silently dropped by watchInserts.
+		msg, er := s.codec.Marshal(data)
+		if er != nil {
+			return er
+		}
+		if msg == nil {
+			atomic.AddUint64(&s.nilMarshalCount, 1)
+			return nil
+		}
+		if bc.StoreSource {
+			s.attachSource(msg, data)
+		}
+		env.data = msg
+		env.marshalled = true
+	}
+	return s.enqueueInsert(env, bc)
+}
+
+// enqueueInsert sends env (an insert or a delete envelope - InsertOne and DeleteOne both funnel
+// through here) on s.inserts while holding openLock's read side, re-checking s.opened under the
+// lock rather than trusting the caller's earlier isOpen() call: that way the check and the send
+// are atomic with respect to Close, which holds the write side while it flips opened and signals
+// shutdown. A send that wins the race lands on a still-fully-open indexer and is picked up either
+// by the normal select loop or by watchInserts' final drain; a send that loses it simply observes
+// opened already false and returns without touching the channel at all. ThrottleHighWaterMark
+// backpressure and InsertBufferMode apply equally to deletes, so a delete-heavy reconciliation
+// burst gets the same protection against outrunning flush as an insert-heavy one.
+func (s *Indexer) enqueueInsert(env insertEnvelope, bc *BleveConfig) error {
+	s.openLock.RLock()
+	defer s.openLock.RUnlock()
 	if !s.opened {
 		return nil
 	}
 	if UnitTestEnv { // blocking insert
-		s.inserts <- data
+		s.inserts <- env
+	} else if hwm := bc.ThrottleHighWaterMark; hwm > 0 && len(s.inserts) >= hwm {
+		// Apply backpressure: block the producer briefly instead of dropping immediately.
+		select {
+		case s.inserts <- env:
+		case <-time.After(bc.ThrottleMaxBlock):
+			atomic.AddUint64(&s.bufferFullCount, 1)
+			if bc.InsertBufferMode == InsertBufferModeError {
+				return ErrIndexBufferFull
+			}
+		}
+	} else if bc.InsertBufferMode == InsertBufferModeBlock {
+		s.inserts <- env
 	} else {
 		select { // non-blocking insert
-		case s.inserts <- data:
+		case s.inserts <- env:
 		default:
+			atomic.AddUint64(&s.bufferFullCount, 1)
+			if bc.InsertBufferMode == InsertBufferModeError {
+				return ErrIndexBufferFull
+			}
 		}
 	}
 	return nil
@@ -259,24 +955,121 @@ func (s *Indexer) InsertOne(ctx context.Context, data interface{}) error {
 
 func (s *Indexer) DeleteOne(ctx context.Context, data interface{}) error {
 
-	if !s.opened {
+	if !s.isOpen() {
 		return nil
 	}
 
-	if UnitTestEnv { // blocking insert
-		s.deletes <- data
-	} else {
-		select { // non-blocking insert
-		case s.deletes <- data:
-		default:
+	bc := s.MustBleveConfig(ctx)
+	if bc.ReadOnly {
+		return ErrIndexerReadOnly
+	}
+	env := insertEnvelope{ctx: ctx, data: data, delete: true}
+	return s.enqueueInsert(env, bc)
+}
+
+// InsertMany marshals every item in data via the codec and commits them as a single
+// *bleve.Batch directly against the current write index, bypassing the inserts channel,
+// crtBatch staging and the background flush cadence entirely - useful for bulk loads (e.g. a
+// migration tool) where the per-item channel InsertOne writes to would bottleneck. It checks
+// ctx for cancellation between items, and rotates the write index afterwards exactly like a
+// regular flush would. It returns the number of documents actually committed, so callers can
+// report progress, alongside any error from Marshal or the final batch commit - on error, the
+// returned count still reflects whatever was committed (0 if the batch commit itself failed).
+func (s *Indexer) InsertMany(ctx context.Context, data []interface{}) (int32, error) {
+	if !s.isOpen() {
+		return 0, nil
+	}
+	bc := s.MustBleveConfig(ctx)
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+
+	idx := s.getWriteIndex()
+	batch := idx.NewBatch()
+	var staged int32
+	for _, item := range data {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		msg, er := s.codec.Marshal(item)
+		if er != nil {
+			return 0, er
+		}
+		if msg == nil {
+			atomic.AddUint64(&s.nilMarshalCount, 1)
+			continue
 		}
+		if bc.StoreSource {
+			s.attachSource(msg, item)
+		}
+		var id string
+		if provider, ok := msg.(dao.IndexIDProvider); ok {
+			id = provider.IndexID()
+		} else {
+			id = xid.New().String()
+		}
+		if err := batch.Index(id, msg); err != nil {
+			return 0, err
+		}
+		s.teeToShadows(id, msg)
+		staged++
 	}
-	return nil
+	if staged == 0 {
+		return 0, nil
+	}
+	if err := idx.Batch(batch); err != nil {
+		return 0, err
+	}
+	atomic.AddInt64(&s.writeSegmentDocCount, int64(staged))
+	s.rotateIfNeeded()
+	return staged, nil
+}
+
+// DeleteByIDs removes a known set of document IDs directly, bypassing the inserts channel and
+// crtBatch staging the way InsertMany bypasses them for bulk inserts: a delete-by-ID through
+// DeleteOne's per-item channel send is needlessly slow for a large set reconciled against a
+// source of truth. It issues one *bleve.Batch per rotated index (an ID not present there costs
+// nothing extra - bleve batch deletes are no-ops for missing IDs) rather than per-ID lookups, and
+// reports how many documents actually disappeared via the DocCount delta around each batch.
+func (s *Indexer) DeleteByIDs(ctx context.Context, ids []string) (int32, error) {
+	if !s.isOpen() {
+		return 0, nil
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	bc := s.MustBleveConfig(ctx)
+	if bc.ReadOnly {
+		return 0, ErrIndexerReadOnly
+	}
+	snap := s.snapshotSegments()
+	var removed int32
+	for _, idx := range snap.indexes {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		before, err := idx.DocCount()
+		if err != nil {
+			return removed, err
+		}
+		batch := idx.NewBatch()
+		for _, id := range ids {
+			batch.Delete(id)
+		}
+		if err := idx.Batch(batch); err != nil {
+			return removed, err
+		}
+		after, err := idx.DocCount()
+		if err != nil {
+			return removed, err
+		}
+		removed += int32(before - after)
+	}
+	return removed, nil
 }
 
 func (s *Indexer) Flush(c context.Context) error {
 
-	if !s.opened {
+	if !s.isOpen() {
 		return nil
 	}
 
@@ -287,6 +1080,28 @@ func (s *Indexer) Flush(c context.Context) error {
 	return nil
 }
 
+// FlushSync behaves like Flush but blocks until watchInserts has actually committed the pending
+// batch, and returns the error from that flush (via LastError), if any. Unlike Flush's
+// fire-and-forget signal, it is meant for tests and transactional callers that need to observe
+// the data as persisted, or the failure, before proceeding.
+func (s *Indexer) FlushSync(ctx context.Context) error {
+	if !s.isOpen() {
+		return nil
+	}
+	done := make(chan error, 1)
+	select {
+	case s.flushSync <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *Indexer) DeleteMany(ctx context.Context, qu interface{}) (int32, error) {
 
 	var q query.Query
@@ -302,55 +1117,273 @@ func (s *Indexer) DeleteMany(ctx context.Context, qu interface{}) (int32, error)
 	req.Size = 10000
 	var count int32
 
-	idx := s.getWriteIndex()
+	// Hold flushLock for the whole operation, the same lock watchInserts takes around flush():
+	// this serializes DeleteMany against the background batch flush, so an in-flight insert or
+	// delete staged in crtBatch can't be lost to (or duplicated by) a concurrent segment write,
+	// and flush() below makes sure crtBatch is committed first so the search below sees it.
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+	s.flush()
+
+	// Snapshot s.indexes by name so hits coming back from the alias - which spans every rotated
+	// segment, not just the active write one - can be routed to the segment that owns them.
+	snapIndexes := s.snapshotSegments().indexes
+	byName := make(map[string]bleve.Index, len(snapIndexes))
+	for _, idx := range snapIndexes {
+		byName[idx.Name()] = idx
+	}
+
 	for {
-		sr, err := idx.SearchInContext(ctx, req)
+		sr, err := s.searchAlias(ctx, req)
 		if err != nil {
-			fmt.Println(err)
-			return 0, err
+			s.logPrintln(err)
+			return count, err
 		}
-		b := idx.NewBatch()
+		batches := make(map[string]*bleve.Batch, len(byName))
 		for _, hit := range sr.Hits {
+			idx, ok := byName[hit.Index]
+			if !ok {
+				continue
+			}
+			b, ok := batches[hit.Index]
+			if !ok {
+				b = idx.NewBatch()
+				batches[hit.Index] = b
+			}
 			b.Delete(hit.ID)
 			count++
 		}
-		if err := idx.Batch(b); err != nil {
-			return count, err
+		for name, b := range batches {
+			if err := byName[name].Batch(b); err != nil {
+				return count, err
+			}
 		}
 		if sr.Total <= uint64(req.Size) {
 			break
 		}
 	}
+	s.refreshTotalDiskUsage()
 
 	return count, nil
 
 }
 
-func (s *Indexer) FindMany(ctx context.Context, query interface{}, offset, limit int32, sortFields string, sortDesc bool, customCodec dao.IndexCodex) (chan interface{}, error) {
-	codec := s.codec
-	if customCodec != nil {
-		codec = customCodec
-	}
-	request, _, err := codec.BuildQuery(query, offset, limit, sortFields, sortDesc)
-	if err != nil {
-		return nil, err
-	}
-	req, ok := request.(*bleve.SearchRequest)
-	if !ok {
-		return nil, fmt.Errorf("Unrecognized searchRequest type")
+// DeleteManyWithCapture behaves like DeleteMany but decodes and passes each matching document to
+// capture before deleting it, in the same search/delete pass, so there is no window in which a
+// record could change or disappear between being read for archival and being purged. If capture
+// returns an error, the whole batch is aborted without deleting it, since compliance requires a
+// copy of everything removed - losing the capture must not silently let the delete through.
+func (s *Indexer) DeleteManyWithCapture(ctx context.Context, qu interface{}, capture func(doc interface{}) error) (int32, error) {
+
+	var q query.Query
+	var str string
+	var ok bool
+	if str, ok = qu.(string); !ok {
+		return 0, fmt.Errorf("DeleteManyWithCapture expects a query string")
+	} else if str == "" {
+		return 0, fmt.Errorf("cannot pass an empty query for deletion")
 	}
-	sr, er := s.searchIndex.SearchInContext(ctx, req)
-	if er != nil {
-		return nil, er
+	q = bleve.NewQueryStringQuery(str)
+	req := bleve.NewSearchRequest(q)
+	req.Size = 10000
+	req.Fields = []string{"*"}
+	var count int32
+
+	// Same locking/snapshot shape as DeleteMany: hold flushLock for the whole operation so an
+	// in-flight insert or delete staged in crtBatch can't be lost to (or duplicated by) a
+	// concurrent segment write, flush crtBatch first so the search below sees it, and route hits
+	// back to the segment that owns them - searchAlias spans every rotated segment, not just the
+	// active write one, so documents archived into older segments are captured and deleted too.
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+	s.flush()
+
+	snapIndexes := s.snapshotSegments().indexes
+	byName := make(map[string]bleve.Index, len(snapIndexes))
+	for _, idx := range snapIndexes {
+		byName[idx.Name()] = idx
 	}
-	cRes := make(chan interface{})
 
-	go func() {
-		defer close(cRes)
-		// Send hits
+	for {
+		sr, err := s.searchAlias(ctx, req)
+		if err != nil {
+			s.logPrintln(err)
+			return count, err
+		}
+		batches := make(map[string]*bleve.Batch, len(byName))
 		for _, hit := range sr.Hits {
-			if result, err := codec.Unmarshal(hit); err == nil {
-				cRes <- result
+			idx, ok := byName[hit.Index]
+			if !ok {
+				continue
+			}
+			doc, er := s.codec.Unmarshal(hit)
+			if er != nil {
+				return count, er
+			}
+			if er := capture(doc); er != nil {
+				return count, er
+			}
+			b, ok := batches[hit.Index]
+			if !ok {
+				b = idx.NewBatch()
+				batches[hit.Index] = b
+			}
+			b.Delete(hit.ID)
+			count++
+		}
+		for name, b := range batches {
+			if err := byName[name].Batch(b); err != nil {
+				return count, err
+			}
+		}
+		if sr.Total <= uint64(req.Size) {
+			break
+		}
+	}
+	s.refreshTotalDiskUsage()
+
+	return count, nil
+}
+
+// ImportNDJSON reads newline-delimited JSON documents from r and bulk-indexes them on the
+// write index, batching inserts by the configured BatchSize. It is the inverse of
+// ExportNDJSON and provides a straightforward backup/restore-by-file workflow. It returns
+// the number of documents imported and stops at the first decode or index error, wrapping it
+// with the offending line number.
+func (s *Indexer) ImportNDJSON(ctx context.Context, r io.Reader, logger func(string)) (int, error) {
+
+	bc := s.MustBleveConfig(ctx)
+	idx := s.getWriteIndex()
+	b := idx.NewBatch()
+	var imported int
+	var line int
+
+	flushBatch := func() error {
+		if b.Size() == 0 {
+			return nil
+		}
+		if er := idx.Batch(b); er != nil {
+			return er
+		}
+		b = idx.NewBatch()
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		var doc map[string]interface{}
+		if er := json.Unmarshal([]byte(raw), &doc); er != nil {
+			return imported, fmt.Errorf("line %d: cannot decode JSON: %s", line, er.Error())
+		}
+		msg, er := s.codec.Marshal(doc)
+		if er != nil {
+			return imported, fmt.Errorf("line %d: cannot marshal document: %s", line, er.Error())
+		}
+		var id string
+		if provider, ok := msg.(dao.IndexIDProvider); ok {
+			id = provider.IndexID()
+		} else {
+			id = xid.New().String()
+		}
+		if er := b.Index(id, msg); er != nil {
+			return imported, fmt.Errorf("line %d: cannot index document: %s", line, er.Error())
+		}
+		imported++
+		if b.Size() >= int(bc.BatchSize) {
+			if er := flushBatch(); er != nil {
+				return imported, fmt.Errorf("line %d: cannot flush batch: %s", line, er.Error())
+			}
+			if logger != nil {
+				logger(fmt.Sprintf("Imported %d documents so far", imported))
+			}
+		}
+	}
+	if er := scanner.Err(); er != nil {
+		return imported, fmt.Errorf("line %d: cannot read input: %s", line, er.Error())
+	}
+	if er := flushBatch(); er != nil {
+		return imported, fmt.Errorf("line %d: cannot flush final batch: %s", line, er.Error())
+	}
+	if logger != nil {
+		logger(fmt.Sprintf("Import done, %d documents imported", imported))
+	}
+
+	return imported, nil
+}
+
+// deriveSearchContext applies BleveConfig.DefaultSearchTimeout to ctx when the caller didn't
+// already give it a deadline of its own, so a client that forgets to bound its context can't
+// wedge a server goroutine on a pathological query across many rotated segments. The returned
+// cancel must always be called once the derived context is no longer needed - when no timeout
+// ends up applying, it's a plain context.WithCancel, so callers don't need to special-case that.
+func (s *Indexer) deriveSearchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	bc := s.MustBleveConfig(ctx)
+	if _, ok := ctx.Deadline(); ok || bc.DefaultSearchTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, bc.DefaultSearchTimeout)
+}
+
+// FindMany runs query and streams decoded hits (and, for a codec implementing dao.FacetParser,
+// facets) on the returned channel from a background goroutine. That goroutine sends on an
+// unbuffered channel, so a caller that stops reading before the channel is closed - an early
+// return, an error path, anything short of draining it to completion - would otherwise leak it
+// forever blocked on send. To avoid that, every send also selects on the (possibly
+// DefaultSearchTimeout-derived, see deriveSearchContext) context being done, so cancelling ctx is
+// enough to make the goroutine exit even if the channel is never drained. Callers that don't
+// intend to consume every hit should still cancel ctx once they're done with it.
+func (s *Indexer) FindMany(ctx context.Context, query interface{}, offset, limit int32, sortFields string, sortDesc bool, customCodec dao.IndexCodex) (chan interface{}, error) {
+	if er := checkResultWindow(s.MustBleveConfig(ctx), offset, limit); er != nil {
+		return nil, er
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(query, offset, limit, sortFields, sortDesc)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		// Send hits
+		segAware, _ := codec.(dao.SegmentAwareUnmarshaler)
+		for _, hit := range sr.Hits {
+			var result interface{}
+			var err error
+			if segAware != nil {
+				result, err = segAware.UnmarshalWithSegment(hit, hit.Index)
+			} else {
+				result, err = codec.Unmarshal(hit)
+			}
+			if err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					// Default or caller timeout hit while a slow consumer hadn't drained
+					// cRes yet: stop feeding it instead of blocking this goroutine forever.
+					return
+				}
 			}
 		}
 		// Parse & send facets
@@ -363,333 +1396,3360 @@ func (s *Indexer) FindMany(ctx context.Context, query interface{}, offset, limit
 	return cRes, nil
 }
 
-func (s *Indexer) SetCodex(c dao.IndexCodex) {
-	s.codec = c
-}
-
-func (s *Indexer) getWriteIndex() bleve.Index {
-	if s.cursor == -1 || len(s.indexes) < s.cursor-1 {
-		// Use a no-op, in-memory index to avoid crashes
-		fmt.Println("[ERROR] Cannot find an available index for writing, entries will be logged in memory")
-		fmt.Println("[ERROR] This should not happen and may indicate a missing MaxConcurrency=1 on the Truncate Logs flow.")
-		fmt.Println("[ERROR] Make sure to fix it and restart if necessary.")
-		idx, _ := s.openOneIndex("", s.MustBleveConfig(context.Background()).MappingName)
-		return idx
+// Explain runs query the same way FindMany would, but narrows it to docID and sets req.Explain so
+// bleve computes its scoring explanation tree, returning that instead of the decoded hit. It's a
+// debugging aid for understanding why one result outranks another - computing the explanation is
+// an expensive per-hit bleve operation, so it's opt-in via this dedicated method rather than a
+// flag on FindMany, which would make every caller pay for it by accident.
+func (s *Indexer) Explain(ctx context.Context, query interface{}, docID string) (*search.Explanation, error) {
+	if docID == "" {
+		return nil, fmt.Errorf("bleve: Explain requires a non-empty docID")
+	}
+	request, _, err := s.codec.BuildQuery(query, 0, 1, "", false)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
 	}
-	return s.indexes[s.cursor]
+	req.Query = bleve.NewConjunctionQuery(req.Query, bleve.NewDocIDQuery([]string{docID}))
+	req.From = 0
+	req.Size = 1
+	req.Explain = true
+	sr, err := s.searchAlias(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(sr.Hits) == 0 {
+		return nil, fmt.Errorf("bleve: no document %q matched query", docID)
+	}
+	return sr.Hits[0].Expl, nil
 }
 
-func (s *Indexer) listIndexes(renameIfNeeded ...bool) (paths []string) {
-	dirPath, base := filepath.Split(s.indexPath)
+// QueryOptions carries call-time query tuning that a codec's BuildQuery has no way to express,
+// since it only ever sees the caller's raw query value, never the resulting query.Query clauses.
+// Right now it only carries FieldBoosts, layered on top of (multiplied with) whatever the
+// codec's own FieldBoostProvider already declares, so a caller can boost a field for one search -
+// e.g. ranking "message" above "metadata" for a single investigative query - without changing the
+// codec's defaults for every other one.
+type QueryOptions struct {
+	// FieldBoosts multiplies the boost of every field-scoped query-string/match clause for a
+	// field of this name, the same traversal FieldBoostProvider's boosts use. A field boosted by
+	// both the codec and here combines multiplicatively.
+	FieldBoosts map[string]float64
+}
 
-	files, err := os.ReadDir(dirPath)
-	if err != nil {
+// applyQueryOptions applies opts's FieldBoosts to req.Query, reusing applyQueryFieldBoosts's
+// conjunction/disjunction/boolean traversal. Like FieldBoostProvider, it is a silent no-op for any
+// query.Query type that traversal doesn't recognize (a composite/custom query the codec built that
+// isn't one of those three, or a leaf that isn't both field-scoped and boostable) - a boost
+// referencing a field nested inside such a query simply never applies. Callers that need boosts
+// inside an opaque composite should have their codec build it pre-boosted instead.
+func applyQueryOptions(req *bleve.SearchRequest, opts *QueryOptions) {
+	if opts == nil || len(opts.FieldBoosts) == 0 {
 		return
 	}
+	applyQueryFieldBoosts(req.Query, opts.FieldBoosts)
+}
 
-	for _, file := range files {
-		if !file.IsDir() {
-			continue
+// FindManyWithOptions behaves like FindMany but additionally accepts opts for call-time query
+// tuning not expressible by the codec - see QueryOptions. A nil opts behaves exactly like FindMany.
+func (s *Indexer) FindManyWithOptions(ctx context.Context, query interface{}, offset, limit int32, sortFields string, sortDesc bool, customCodec dao.IndexCodex, opts *QueryOptions) (chan interface{}, error) {
+	if er := checkResultWindow(s.MustBleveConfig(ctx), offset, limit); er != nil {
+		return nil, er
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(query, offset, limit, sortFields, sortDesc)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	applyQueryOptions(req, opts)
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		segAware, _ := codec.(dao.SegmentAwareUnmarshaler)
+		for _, hit := range sr.Hits {
+			var result interface{}
+			var err error
+			if segAware != nil {
+				result, err = segAware.UnmarshalWithSegment(hit, hit.Index)
+			} else {
+				result, err = codec.Unmarshal(hit)
+			}
+			if err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
 		}
-		curBase := filepath.Base(file.Name())
-		if curBase == base {
-			paths = append(paths, curBase)
-		} else if strings.HasPrefix(curBase, base) {
-			// Ensure suffix is a number ".0001", ".0002", etc.
-			test := strings.TrimLeft(strings.TrimPrefix(curBase, base+"."), "0")
-			if _, e := strconv.ParseInt(test, 10, 32); e == nil {
-				paths = append(paths, curBase)
+		if fParser, ok := codec.(dao.FacetParser); ok {
+			for _, facet := range sr.Facets {
+				fParser.UnmarshalFacet(facet, cRes)
 			}
 		}
+	}()
+	return cRes, nil
+}
+
+// Count returns the number of documents matching query, across however many rotated segments
+// the alias currently spans, without paging through the hits themselves. It builds the search
+// request through the codec like FindMany, then sets Size to 0 so bleve only needs to compute
+// the total, not fetch and score documents. It respects ctx cancellation via searchAlias.
+func (s *Indexer) Count(ctx context.Context, query interface{}, customCodec dao.IndexCodex) (uint64, error) {
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
 	}
-	sort.Strings(paths)
-	if len(renameIfNeeded) > 0 && renameIfNeeded[0] && len(paths) > 0 && paths[0] != base {
-		// Old files were removed, renumber files
-		for _, p := range paths {
-			src := filepath.Join(dirPath, p)
-			t1 := filepath.Join(dirPath, fmt.Sprintf("%s-rename", p))
-			os.Rename(src, t1)
+	request, _, err := codec.BuildQuery(query, 0, 0, "", false)
+	if err != nil {
+		return 0, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return 0, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	req.Size = 0
+	sr, er := s.searchAlias(ctx, req)
+	if er != nil {
+		return 0, er
+	}
+	return sr.Total, nil
+}
+
+// FindManyWithSort behaves like FindMany but lets the caller override the ordering that would
+// otherwise come entirely from whatever the codec bakes into BuildQuery, via
+// bleve.SearchRequest.SortBy. Each entry in sort is a field name, optionally prefixed with "-"
+// for descending, exactly as SortBy expects (e.g. []string{"-Ts", "-Nano"} for stable
+// reverse-chronological paging, or []string{"-Level"} to page by severity instead). An empty
+// sort falls back to whatever BuildQuery already set.
+func (s *Indexer) FindManyWithSort(ctx context.Context, query interface{}, offset, limit int32, sort []string, customCodec dao.IndexCodex) (chan interface{}, error) {
+	if er := checkResultWindow(s.MustBleveConfig(ctx), offset, limit); er != nil {
+		return nil, er
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(query, offset, limit, "", false)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	if len(sort) > 0 {
+		req.SortBy(sort)
+	}
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		segAware, _ := codec.(dao.SegmentAwareUnmarshaler)
+		for _, hit := range sr.Hits {
+			var result interface{}
+			var err error
+			if segAware != nil {
+				result, err = segAware.UnmarshalWithSegment(hit, hit.Index)
+			} else {
+				result, err = codec.Unmarshal(hit)
+			}
+			if err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
 		}
-		for i, p := range paths {
-			src := filepath.Join(dirPath, fmt.Sprintf("%s-rename", p))
-			t2 := filepath.Join(dirPath, fmt.Sprintf("%s.%04d", base, i))
-			if i == 0 {
-				t2 = s.indexPath
+		if fParser, ok := codec.(dao.FacetParser); ok {
+			for _, facet := range sr.Facets {
+				fParser.UnmarshalFacet(facet, cRes)
 			}
-			os.Rename(src, t2)
 		}
-		return s.listIndexes()
+	}()
+	return cRes, nil
+}
+
+// FindManyAfter is a cursor-based alternative to FindMany/FindManyWithSort for paging deep into
+// large result sets. Offset-based paging makes bleve walk and discard every hit before offset on
+// every request, which gets expensive fast once offset climbs into the tens of thousands across
+// rotated segments. FindManyAfter instead sets req.SearchAfter to searchAfter (the sort values of
+// the last hit the caller already consumed, or nil for the first page), so bleve can seek
+// straight to that position. sort follows the same convention as FindManyWithSort - field names
+// optionally prefixed with "-" for descending - and must be non-empty since SearchAfter requires
+// a deterministic order to seek against.
+//
+// It returns the decoded hits on cRes as usual, plus the sort values of the last hit in this
+// page, ready to be passed back in as searchAfter for the next call. next is nil once the page
+// came back empty, signalling the caller has reached the end.
+func (s *Indexer) FindManyAfter(ctx context.Context, query interface{}, sort []string, searchAfter []string, limit int32, customCodec dao.IndexCodex) (cRes chan interface{}, next []string, er error) {
+	if len(sort) == 0 {
+		return nil, nil, fmt.Errorf("FindManyAfter requires a non-empty sort to seek against")
 	}
-	return
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(query, 0, limit, "", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	req.From = 0
+	req.SortBy(sort)
+	if len(searchAfter) > 0 {
+		req.SearchAfter = searchAfter
+	}
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, err := s.searchAlias(sctx, req)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	cRes = make(chan interface{})
+	if len(sr.Hits) > 0 {
+		last := sr.Hits[len(sr.Hits)-1]
+		next = append([]string{}, last.Sort...)
+	}
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		segAware, _ := codec.(dao.SegmentAwareUnmarshaler)
+		for _, hit := range sr.Hits {
+			var result interface{}
+			var err error
+			if segAware != nil {
+				result, err = segAware.UnmarshalWithSegment(hit, hit.Index)
+			} else {
+				result, err = codec.Unmarshal(hit)
+			}
+			if err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return cRes, next, nil
 }
 
-func (s *Indexer) watchInserts() {
-	bc := s.MustBleveConfig(context.Background())
-	batchSize := int(bc.BatchSize)
-	for {
-		select {
-		case in := <-s.inserts:
-			msg, er := s.codec.Marshal(in)
-			if er != nil {
-				break
+// StreamAll is the read-side complement to InsertMany: a full-index export/dump for backup and
+// ETL use cases that would otherwise have to misuse FindMany with a match-all query and manual
+// offset paging. It walks the whole alias using the same SearchAfter seeking as FindManyAfter,
+// sorted by the "_id" meta field (stable and unique within a segment), repeating internally page
+// after page until one comes back empty - so rotation boundaries are invisible to the caller, who
+// just drains the returned channel once. codec may be nil, in which case the indexer's own codec
+// is used. Like FindMany, every send selects on ctx being done, so the caller can stop the export
+// early by cancelling ctx instead of having to drain the channel to completion.
+func (s *Indexer) StreamAll(ctx context.Context, codec dao.IndexCodex) (chan interface{}, error) {
+	if codec == nil {
+		codec = s.codec
+	}
+	cRes := make(chan interface{})
+	go func() {
+		defer close(cRes)
+		segAware, _ := codec.(dao.SegmentAwareUnmarshaler)
+		var searchAfter []string
+		for {
+			if err := ctx.Err(); err != nil {
+				return
 			}
-			s.flushLock.Lock()
-			if s.crtBatch == nil {
-				s.crtBatch = s.getWriteIndex().NewBatch()
+			req := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+			req.Size = 5000
+			req.Fields = []string{"*"}
+			req.SortBy([]string{"_id"})
+			if len(searchAfter) > 0 {
+				req.SearchAfter = searchAfter
 			}
-			var id string
-			if provider, ok := msg.(dao.IndexIDProvider); ok {
-				id = provider.IndexID()
-			} else {
-				id = xid.New().String()
+			sr, err := s.searchAlias(ctx, req)
+			if err != nil {
+				s.logPrintln("[pydio.grpc.log] StreamAll search failed", err)
+				return
 			}
-			s.crtBatch.Index(id, msg)
-			if s.crtBatch.Size() >= batchSize {
-				s.flush()
+			if len(sr.Hits) == 0 {
+				return
 			}
-			s.flushLock.Unlock()
-		case del := <-s.deletes:
-			if id, o := del.(string); o {
-				s.flushLock.Lock()
-				if s.crtBatch == nil {
-					s.crtBatch = s.getWriteIndex().NewBatch()
+			for _, hit := range sr.Hits {
+				var result interface{}
+				var err error
+				if segAware != nil {
+					result, err = segAware.UnmarshalWithSegment(hit, hit.Index)
+				} else {
+					result, err = codec.Unmarshal(hit)
 				}
-				s.crtBatch.Delete(id)
-				if s.crtBatch.Size() >= batchSize {
-					s.flush()
+				if err != nil {
+					continue
+				}
+				select {
+				case cRes <- result:
+				case <-ctx.Done():
+					return
 				}
-				s.flushLock.Unlock()
-			}
-		case <-s.forceFlush:
-			s.flushLock.Lock()
-			s.flush()
-			s.flushLock.Unlock()
-		case <-time.After(3 * time.Second):
-			s.flushLock.Lock()
-			s.flush()
-			s.flushLock.Unlock()
-		case <-s.insertsDone:
-			s.flushLock.Lock()
-			s.flush()
-			s.flushLock.Unlock()
-			s.searchIndex.Close()
-			for _, i := range s.indexes {
-				i.Close()
 			}
-			return
+			last := sr.Hits[len(sr.Hits)-1]
+			searchAfter = append([]string{}, last.Sort...)
 		}
+	}()
+	return cRes, nil
+}
+
+// FindManyWithHighlight behaves like FindMany but additionally requests bleve to compute
+// highlighted fragments for the matched terms, via req.Highlight = bleve.NewHighlight(). When
+// fields is non-empty, only those fields are highlighted; otherwise bleve highlights every field
+// referenced by the query. Highlighting has a real scoring/formatting cost, which is why it isn't
+// baked into FindMany itself - callers opt in by calling this method instead.
+//
+// If the codec implements dao.HighlightParser, UnmarshalWithHighlight is called directly with the
+// hit's fragments. Otherwise results fall back to the existing codec.Unmarshal +
+// attachHighlightFragments path, so callers only implementing dao.HighlightReceiver keep working
+// unchanged.
+func (s *Indexer) FindManyWithHighlight(ctx context.Context, query interface{}, offset, limit int32, sortFields string, sortDesc bool, fields []string, customCodec dao.IndexCodex) (chan interface{}, error) {
+	if er := checkResultWindow(s.MustBleveConfig(ctx), offset, limit); er != nil {
+		return nil, er
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(query, offset, limit, sortFields, sortDesc)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	req.Highlight = bleve.NewHighlight()
+	if len(fields) > 0 {
+		req.Highlight.Fields = fields
+	}
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
 	}
+	cRes := make(chan interface{})
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		hParser, _ := codec.(dao.HighlightParser)
+		segAware, _ := codec.(dao.SegmentAwareUnmarshaler)
+		for _, hit := range sr.Hits {
+			var result interface{}
+			var err error
+			if hParser != nil {
+				result, err = hParser.UnmarshalWithHighlight(hit, hit.Fragments)
+			} else if segAware != nil {
+				result, err = segAware.UnmarshalWithSegment(hit, hit.Index)
+				if err == nil {
+					attachHighlightFragments(result, hit)
+				}
+			} else {
+				result, err = codec.Unmarshal(hit)
+				if err == nil {
+					attachHighlightFragments(result, hit)
+				}
+			}
+			if err == nil {
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+		if fParser, ok := codec.(dao.FacetParser); ok {
+			for _, facet := range sr.Facets {
+				fParser.UnmarshalFacet(facet, cRes)
+			}
+		}
+	}()
+	return cRes, nil
 }
 
-func (s *Indexer) rotateIfNeeded() {
-	bc := s.MustBleveConfig(context.Background())
+// HitResult wraps a hit pushed onto FindManyTyped's channel, distinguishing it by type from
+// FacetResult so consumers can render hits and aggregations independently without guessing
+// based on the codec's own concrete types.
+type HitResult struct {
+	Data interface{}
+}
 
-	if s.indexPath == "" || bc.RotationSize == -1 {
+// FacetResult wraps a facet value pushed by the codec's dao.FacetParser onto FindManyTyped's
+// channel. See HitResult.
+type FacetResult struct {
+	Data interface{}
+}
+
+// FindManyTyped behaves like FindMany but wraps every channel item in HitResult or FacetResult,
+// so a streaming UI can tell aggregations and hits apart by type switch instead of assuming they
+// never collide. Facets are only sent if the codec implements dao.FacetParser.
+func (s *Indexer) FindManyTyped(ctx context.Context, query interface{}, offset, limit int32, sortFields string, sortDesc bool, customCodec dao.IndexCodex) (chan interface{}, error) {
+	if er := checkResultWindow(s.MustBleveConfig(ctx), offset, limit); er != nil {
+		return nil, er
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(query, offset, limit, sortFields, sortDesc)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		// Send hits
+		segAware, _ := codec.(dao.SegmentAwareUnmarshaler)
+		for _, hit := range sr.Hits {
+			var result interface{}
+			var err error
+			if segAware != nil {
+				result, err = segAware.UnmarshalWithSegment(hit, hit.Index)
+			} else {
+				result, err = codec.Unmarshal(hit)
+			}
+			if err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- HitResult{Data: result}:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+		// Parse & send facets, relaying them through an intermediate channel so each one can be
+		// wrapped as it comes out of the codec's FacetParser (which pushes directly).
+		if fParser, ok := codec.(dao.FacetParser); ok {
+			facets := make(chan interface{})
+			done := make(chan bool)
+			go func() {
+				defer close(done)
+				for f := range facets {
+					select {
+					case cRes <- FacetResult{Data: f}:
+					case <-sctx.Done():
+						// Keep draining facets so the loop pushing into it below never
+						// blocks forever on a reader that stopped forwarding.
+						for range facets {
+						}
+						return
+					}
+				}
+			}()
+			for _, facet := range sr.Facets {
+				fParser.UnmarshalFacet(facet, facets)
+			}
+			close(facets)
+			<-done
+		}
+	}()
+	return cRes, nil
+}
+
+// FieldBoostProvider lets a codec declare per-field relevance boosts, keyed by field name.
+// bleve v2 dropped the index-time field-mapping boost that v1 had on FieldMapping - boosting is
+// now always resolved at query time - so GetFieldBoosts is applied automatically to every query
+// clause touching that field, right before the query runs, instead of requiring every caller to
+// set it by hand on a per-query basis. It combines multiplicatively with whatever boost the
+// caller's own query already carries, so per-query boosting (see QueryOptions and
+// FindManyWithOptions) still works for finer control.
+type FieldBoostProvider interface {
+	GetFieldBoosts(sc configx.Values) map[string]float64
+}
+
+// applyFieldBoosts multiplies the boost of every field-scoped clause in req.Query by the codec's
+// configured per-field boost, if any. It is a no-op when the codec doesn't implement
+// FieldBoostProvider or declares no boosts.
+func (s *Indexer) applyFieldBoosts(req *bleve.SearchRequest) {
+	provider, ok := s.codec.(FieldBoostProvider)
+	if !ok {
+		return
+	}
+	boosts := provider.GetFieldBoosts(s.serviceConfigs)
+	if len(boosts) == 0 {
+		return
+	}
+	applyQueryFieldBoosts(req.Query, boosts)
+}
+
+// applyQueryFieldBoosts recurses into compound queries (conjunction/disjunction/boolean) to reach
+// every leaf clause, and multiplies the boost of any clause that is both field-scoped and
+// boostable by the configured boost for its field.
+func applyQueryFieldBoosts(q query.Query, boosts map[string]float64) {
+	switch qq := q.(type) {
+	case nil:
+		return
+	case *query.ConjunctionQuery:
+		for _, c := range qq.Conjuncts {
+			applyQueryFieldBoosts(c, boosts)
+		}
+		return
+	case *query.DisjunctionQuery:
+		for _, d := range qq.Disjuncts {
+			applyQueryFieldBoosts(d, boosts)
+		}
+		return
+	case *query.BooleanQuery:
+		applyQueryFieldBoosts(qq.Must, boosts)
+		applyQueryFieldBoosts(qq.Should, boosts)
+		applyQueryFieldBoosts(qq.MustNot, boosts)
+		return
+	}
+	fq, ok := q.(query.FieldableQuery)
+	if !ok {
+		return
+	}
+	boost, ok := boosts[fq.Field()]
+	if !ok {
+		return
+	}
+	if bq, ok := q.(query.BoostableQuery); ok {
+		bq.SetBoost(bq.Boost() * boost)
+	}
+}
+
+// searchAlias runs req against every open segment, honoring AliasSearchConcurrency: when unset
+// it delegates straight to the search alias (bleve's own unbounded concurrency), otherwise it
+// searches the segments in batches of that size to cap how many scorch searches run at once.
+func (s *Indexer) searchAlias(ctx context.Context, req *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	bc := s.MustBleveConfig(ctx)
+	s.applyFieldBoosts(req)
+	limit := bc.AliasSearchConcurrency
+	snap := s.snapshotSegments()
+	return s.runSearch(ctx, bc, func() (*bleve.SearchResult, error) {
+		if limit <= 0 || limit >= len(snap.indexes) {
+			return snap.searchIndex.SearchInContext(ctx, req)
+		}
+		return limitedAliasSearch(ctx, req, limit, snap.indexes)
+	})
+}
+
+// runSearch wraps fn - an actual SearchInContext call against whatever target the caller already
+// chose (the full alias, a concurrency-limited subset, or SearchTimeWindow's time-narrowed
+// subset) - with the search-duration/count metrics and circuit breaker bookkeeping every search
+// path shares, so a caller searching something other than the full alias doesn't have to
+// duplicate that bookkeeping.
+func (s *Indexer) runSearch(ctx context.Context, bc *BleveConfig, fn func() (*bleve.SearchResult, error)) (*bleve.SearchResult, error) {
+	start := time.Now()
+	defer func() {
+		atomic.AddUint64(&s.searchCount, 1)
+		atomic.AddUint64(&s.searchNanos, uint64(time.Since(start).Nanoseconds()))
+	}()
+	if bc.SearchBreakerThreshold > 0 {
+		if retryAfter, open := s.searchBreakerStatus(); open {
+			return nil, fmt.Errorf("%w, retry after %s", ErrSearchUnavailable, retryAfter.Round(time.Second))
+		}
+	}
+	sr, er := fn()
+	if bc.SearchBreakerThreshold > 0 {
+		s.recordSearchBreakerResult(er == nil, bc)
+	}
+	if er == nil && sr != nil {
+		s.recordSearchStatus(ctx, sr.Status)
+	}
+	return sr, er
+}
+
+// searchBreakerStatus reports whether the search circuit breaker is currently open, and if so
+// how long until it is eligible to close.
+func (s *Indexer) searchBreakerStatus() (time.Duration, bool) {
+	s.searchBreakerLock.Lock()
+	defer s.searchBreakerLock.Unlock()
+	if remaining := time.Until(s.searchBreakerOpenUntil); remaining > 0 {
+		return remaining, true
+	}
+	return 0, false
+}
+
+// recordSearchBreakerResult updates the consecutive failure count backing the search circuit
+// breaker: a success resets it, a failure trips the breaker open for SearchBreakerCooldown once
+// SearchBreakerThreshold consecutive failures have been seen.
+func (s *Indexer) recordSearchBreakerResult(success bool, bc *BleveConfig) {
+	s.searchBreakerLock.Lock()
+	defer s.searchBreakerLock.Unlock()
+	if success {
+		s.searchBreakerFailures = 0
+		return
+	}
+	s.searchBreakerFailures++
+	if s.searchBreakerFailures >= bc.SearchBreakerThreshold {
+		cooldown := bc.SearchBreakerCooldown
+		if cooldown <= 0 {
+			cooldown = DefaultSearchBreakerCooldown
+		}
+		s.searchBreakerOpenUntil = time.Now().Add(cooldown)
+	}
+}
+
+// Health reports the current state of the search circuit breaker (see SearchBreakerThreshold),
+// so callers can surface degraded read availability before every search starts timing out.
+func (s *Indexer) Health() map[string]interface{} {
+	retryAfter, open := s.searchBreakerStatus()
+	s.searchBreakerLock.Lock()
+	failures := s.searchBreakerFailures
+	s.searchBreakerLock.Unlock()
+	h := map[string]interface{}{
+		"SearchBreakerOpen":     open,
+		"SearchBreakerFailures": failures,
+	}
+	if open {
+		h["SearchBreakerRetryAfter"] = retryAfter.Round(time.Second).String()
+	}
+	return h
+}
+
+// recordSearchStatus keeps track of the last time a search came back with some segments failing
+// (bleve's alias/MultiSearch already tolerates this and returns the hits it could get), so
+// availability degradation is observable instead of silent. See LastSearchWarning.
+func (s *Indexer) recordSearchStatus(ctx context.Context, status *bleve.SearchStatus) {
+	if status == nil || status.Failed == 0 {
 		return
 	}
-	checkPath := s.indexPath
-	if s.cursor > 0 {
-		checkPath = fmt.Sprintf("%s.%04d", s.indexPath, s.cursor)
+	var failed []string
+	for name := range status.Errors {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	warning := fmt.Sprintf("search degraded: %d/%d segments failed (%s)", status.Failed, status.Total, strings.Join(failed, ", "))
+	s.searchWarnLock.Lock()
+	s.lastSearchWarning = warning
+	s.searchWarnLock.Unlock()
+	log.Logger(ctx).Warn("[pydio.grpc.log] " + warning)
+}
+
+// LastSearchWarning returns a description of the most recent search that returned partial
+// results because one or more segments failed, or "" if no search has degraded so far.
+func (s *Indexer) LastSearchWarning() string {
+	s.searchWarnLock.Lock()
+	defer s.searchWarnLock.Unlock()
+	return s.lastSearchWarning
+}
+
+// limitedAliasSearch reproduces bleve's own IndexAlias search/merge/trim logic (query every
+// index, merge hits, re-sort, then apply From/Size), but processes indexes in batches of at most
+// limit instead of all at once, so no more than limit scorch searches run concurrently.
+// Correctness holds because each batch is asked for its own top (From+Size) hits: any document
+// belonging to the global top (From+Size) must already be within its own batch's local top
+// (From+Size), so nothing is lost by trimming per batch before the final merge.
+func limitedAliasSearch(ctx context.Context, req *bleve.SearchRequest, limit int, indexes []bleve.Index) (*bleve.SearchResult, error) {
+	if len(indexes) == 0 {
+		return &bleve.SearchResult{Status: &bleve.SearchStatus{Errors: make(map[string]error)}}, nil
+	}
+	childReq := &bleve.SearchRequest{
+		Query:            req.Query,
+		Size:             req.Size + req.From,
+		From:             0,
+		Highlight:        req.Highlight,
+		Fields:           req.Fields,
+		Facets:           req.Facets,
+		Explain:          req.Explain,
+		Sort:             req.Sort.Copy(),
+		IncludeLocations: req.IncludeLocations,
+		Score:            req.Score,
+		SearchAfter:      req.SearchAfter,
+		SearchBefore:     req.SearchBefore,
+	}
+	var merged *bleve.SearchResult
+	for i := 0; i < len(indexes); i += limit {
+		end := i + limit
+		if end > len(indexes) {
+			end = len(indexes)
+		}
+		sr, err := bleve.MultiSearch(ctx, childReq, indexes[i:end]...)
+		if err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			merged = sr
+		} else {
+			merged.Merge(sr)
+		}
+	}
+	if len(req.Sort) > 0 {
+		cachedScoring := req.Sort.CacheIsScore()
+		cachedDesc := req.Sort.CacheDescending()
+		sort.SliceStable(merged.Hits, func(a, b int) bool {
+			return req.Sort.Compare(cachedScoring, cachedDesc, merged.Hits[a], merged.Hits[b]) < 0
+		})
+	}
+	if req.From > 0 {
+		if len(merged.Hits) > req.From {
+			merged.Hits = merged.Hits[req.From:]
+		} else {
+			merged.Hits = search.DocumentMatchCollection{}
+		}
+	}
+	if req.Size > 0 && len(merged.Hits) > req.Size {
+		merged.Hits = merged.Hits[:req.Size]
+	}
+	for name, fr := range req.Facets {
+		merged.Facets.Fixup(name, fr.Size)
+	}
+	merged.Request = req
+	return merged, nil
+}
+
+// MergeFacetResults combines the facet results of several independent searches (e.g. one per
+// shard in a sharded deployment) into a single result, summing term counts and ranges for
+// facets found in more than one result. It builds on bleve's own FacetResults.Merge, which
+// already implements the per-field merge logic.
+func MergeFacetResults(results ...search.FacetResults) search.FacetResults {
+	merged := search.FacetResults{}
+	for _, fr := range results {
+		merged.Merge(fr)
+	}
+	return merged
+}
+
+// FacetTerm is one distinct value of a field and how many documents carry it, as returned by
+// DistinctValues.
+type FacetTerm struct {
+	Term  string
+	Count int
+}
+
+// DistinctValues returns the distinct values of field across the whole index and how many
+// documents carry each one, found via a term facet over a MatchAll query capped at max terms.
+// It backs UI filter dropdowns ("filter by level", "filter by service"), which would otherwise
+// have to hand-build the same facet search. max is also a safety cap: like any bleve term facet,
+// counts are exact only up to the per-segment top-max terms tracked internally, so for
+// high-cardinality fields the tail values excluded from a segment's top-max before the merge
+// will be under-represented rather than accurately zero.
+func (s *Indexer) DistinctValues(ctx context.Context, field string, max int) ([]FacetTerm, error) {
+	if max <= 0 {
+		max = 100
+	}
+	req := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	req.Size = 0
+	req.AddFacet(field, bleve.NewFacetRequest(field, max))
+	sr, err := s.searchAlias(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	fr, ok := sr.Facets[field]
+	if !ok || fr.Terms == nil {
+		return nil, nil
+	}
+	terms := fr.Terms.Terms()
+	values := make([]FacetTerm, 0, len(terms))
+	for _, t := range terms {
+		values = append(values, FacetTerm{Term: t.Term, Count: t.Count})
+	}
+	return values, nil
+}
+
+// MappingFingerprint returns a stable hash of the effective index mapping in use by the current
+// write index. Consumers can embed it in external cache keys so that a mapping change (which can
+// change search result semantics) automatically invalidates those caches. It returns an empty
+// string if no index is currently open.
+func (s *Indexer) MappingFingerprint() string {
+	if snap := s.snapshotSegments(); snap.cursor < 0 || snap.cursor >= len(snap.indexes) {
+		return ""
+	}
+	return fingerprintMapping(s.getWriteIndex())
+}
+
+// fingerprintMapping hashes idx's effective mapping the same way MappingFingerprint does, but
+// takes the index explicitly so callers that already have one in hand (e.g. Open, right after
+// creating it, before getWriteIndex has anything to find) don't have to go through the write
+// segment lookup. It returns "" for a nil index.
+func fingerprintMapping(idx bleve.Index) string {
+	if idx == nil {
+		return ""
+	}
+	return fingerprintMappingValue(idx.Mapping())
+}
+
+// fingerprintMappingValue hashes any mapping value the same way fingerprintMapping does, for
+// callers that have a *mapping.IndexMappingImpl in hand (e.g. one built by buildTargetMapping)
+// rather than an opened bleve.Index.
+func fingerprintMappingValue(m interface{}) string {
+	if m == nil {
+		return ""
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// mappingVersionFilePath returns the path of the small file recording the mapping fingerprint
+// this indexer's on-disk segments were created with, e.g. "logs.mapping-version" next to "logs".
+func (s *Indexer) mappingVersionFilePath() string {
+	dirPath, base := filepath.Split(s.indexPath)
+	return filepath.Join(dirPath, base+".mapping-version")
+}
+
+// recordMappingVersion persists fingerprint as the mapping version for this indexer's segments.
+// Errors are logged, never returned - same rationale as recordManifestSegment: this is a
+// convenience layer that must never block indexing.
+func (s *Indexer) recordMappingVersion(fingerprint string) {
+	if err := os.WriteFile(s.mappingVersionFilePath(), []byte(fingerprint), 0644); err != nil {
+		s.logPrintln("[pydio.grpc.log] Could not record mapping version", err)
+	}
+}
+
+// loadMappingVersion reads back the mapping fingerprint recorded by recordMappingVersion. A
+// missing file returns "", the same as an indexer created before this feature existed - which
+// MappingVersionMismatch treats as "nothing to compare against" rather than a mismatch.
+func (s *Indexer) loadMappingVersion() string {
+	b, err := os.ReadFile(s.mappingVersionFilePath())
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// buildTargetMapping constructs the mapping a brand new segment would get if opened right now,
+// following the exact same steps openOneIndex's create path does (custom analysis from config,
+// then from the codec, then the codec's document mapping and any analyzer-override models) but
+// without creating an actual bleve index, so it can be compared against what's already on disk
+// without the cost of opening anything.
+func (s *Indexer) buildTargetMapping() (*mapping.IndexMappingImpl, error) {
+	im := bleve.NewIndexMapping()
+	if err := registerCustomAnalysis(im, s.serviceConfigs); err != nil {
+		return nil, err
+	}
+	if err := registerCodecAnalysis(im, s.codec); err != nil {
+		return nil, err
+	}
+	if model, ok := s.codec.GetModel(s.serviceConfigs); ok {
+		if docMapping, ok := model.(*mapping.DocumentMapping); ok {
+			im.AddDocumentMapping(s.MustBleveConfig(context.Background()).MappingName, docMapping)
+		}
+	}
+	if overrider, ok := s.codec.(AnalyzerOverrideModelProvider); ok {
+		for typeName, docMapping := range overrider.GetAnalyzerOverrideModels(s.serviceConfigs) {
+			im.AddDocumentMapping(typeName, docMapping)
+		}
+	}
+	return im, nil
+}
+
+// MappingVersionMismatch reports whether the mapping version recorded for this indexer's segments
+// (see recordMappingVersion) differs from the mapping the current codec would build today. A
+// missing recorded version, or an in-memory indexer (no indexPath to record one against), never
+// counts as a mismatch - there is nothing to compare against yet. Open calls this and logs a
+// warning when it is true; follow up with MigrateMapping to actually reindex.
+func (s *Indexer) MappingVersionMismatch() (bool, error) {
+	if s.indexPath == "" {
+		return false, nil
+	}
+	recorded := s.loadMappingVersion()
+	if recorded == "" {
+		return false, nil
+	}
+	target, err := s.buildTargetMapping()
+	if err != nil {
+		return false, err
+	}
+	return recorded != fingerprintMappingValue(target), nil
+}
+
+// MigrateMapping checks for a mapping version mismatch (see MappingVersionMismatch) and, if one is
+// found, reindexes every document into a fresh copy built from the codec's current mapping by
+// calling Resync - the same reindex-into-a-new-copy machinery originally written for the bolt to
+// bleve format switch applies equally well here, since Resync already rebuilds the copy through
+// the codec's current Marshal/Unmarshal and GetModel. It records the new mapping version once
+// Resync succeeds, and is a no-op, not an error, when there is nothing to migrate.
+func (s *Indexer) MigrateMapping(ctx context.Context, logger func(string)) error {
+	mismatch, err := s.MappingVersionMismatch()
+	if err != nil {
+		return err
+	}
+	if !mismatch {
+		return nil
+	}
+	if err := s.Resync(ctx, logger); err != nil {
+		return err
+	}
+	s.recordMappingVersion(s.MappingFingerprint())
+	return nil
+}
+
+// GetByIDs retrieves several documents in one search instead of issuing one lookup per ID. The
+// returned map is keyed by document ID; IDs that do not exist are simply absent, no error.
+func (s *Indexer) GetByIDs(ctx context.Context, ids []string) (map[string]interface{}, error) {
+	results := make(map[string]interface{})
+	if len(ids) == 0 {
+		return results, nil
+	}
+	req := bleve.NewSearchRequest(bleve.NewDocIDQuery(ids))
+	req.Size = len(ids)
+	req.Fields = []string{"*"}
+	sr, er := s.searchAlias(ctx, req)
+	if er != nil {
+		return nil, er
+	}
+	for _, hit := range sr.Hits {
+		if result, err := s.codec.Unmarshal(hit); err == nil {
+			results[hit.ID] = result
+		}
+	}
+	return results, nil
+}
+
+// ExpungeDeletes forces scorch to merge away tombstoned documents across all rotated
+// indexes, reclaiming the disk space held by large DeleteMany/Truncate operations instead of
+// waiting for background merges. It returns the approximate number of bytes freed.
+func (s *Indexer) ExpungeDeletes(ctx context.Context) (int64, error) {
+	var freed int64
+	for _, idx := range s.snapshotSegments().indexes {
+		adv, er := idx.Advanced()
+		if er != nil {
+			continue
+		}
+		sc, ok := adv.(*scorch.Scorch)
+		if !ok {
+			continue
+		}
+		before := reclaimableBytes(sc)
+		if er := sc.ForceMerge(ctx, nil); er != nil {
+			return freed, er
+		}
+		after := reclaimableBytes(sc)
+		if before > after {
+			freed += before - after
+		}
+	}
+	return freed, nil
+}
+
+func reclaimableBytes(sc *scorch.Scorch) int64 {
+	if v, ok := sc.StatsMap()["num_bytes_used_disk_by_root_reclaimable"].(uint64); ok {
+		return int64(v)
+	}
+	return 0
+}
+
+// SearchTimeRange combines textQuery with a date-range filter on field (defaulting to the
+// configured TimeField) in a boolean must, sparing callers from building the date-range
+// query by hand for the most common log-search pattern.
+func (s *Indexer) SearchTimeRange(ctx context.Context, textQuery interface{}, field string, from, to time.Time, offset, limit int32) (chan interface{}, error) {
+	if field == "" {
+		field = s.MustBleveConfig(ctx).TimeField
+	}
+	if field == "" {
+		return nil, fmt.Errorf("no time field configured or provided")
+	}
+	request, _, err := s.codec.BuildQuery(textQuery, offset, limit, "", false)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	dateQuery := bleve.NewDateRangeQuery(from, to)
+	dateQuery.SetField(field)
+	req.Query = bleve.NewConjunctionQuery(req.Query, dateQuery)
+
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		for _, hit := range sr.Hits {
+			if result, err := s.codec.Unmarshal(hit); err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return cRes, nil
+}
+
+// SearchTimeWindow behaves like SearchTimeRange, but additionally narrows the search down to only
+// the rotated segments whose recorded time range (see ManifestEntry.MinTime/MaxTime, populated by
+// freezeSegmentTimeRange when a segment is rotated off the write path) overlaps [from, to],
+// building a temporary IndexAlias over just that subset instead of querying every segment the way
+// SearchTimeRange's full-alias search does. A segment with no recorded range yet - the current
+// write segment, which is still growing, or one rotated before this feature existed or without a
+// time field configured - is always included, so a gap in the manifest only costs extra work,
+// never a missed match. For a "last 24h" dashboard query against years of retained logs this
+// touches a handful of segments instead of all of them.
+func (s *Indexer) SearchTimeWindow(ctx context.Context, textQuery interface{}, field string, from, to time.Time, offset, limit int32) (chan interface{}, error) {
+	if field == "" {
+		field = s.MustBleveConfig(ctx).TimeField
+	}
+	if field == "" {
+		return nil, fmt.Errorf("no time field configured or provided")
+	}
+	request, _, err := s.codec.BuildQuery(textQuery, offset, limit, "", false)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	dateQuery := bleve.NewDateRangeQuery(from, to)
+	dateQuery.SetField(field)
+	req.Query = bleve.NewConjunctionQuery(req.Query, dateQuery)
+
+	bc := s.MustBleveConfig(ctx)
+	s.applyFieldBoosts(req)
+	ranges := s.manifestEntriesWithRange(field)
+	snap := s.snapshotSegments()
+	subset := make([]bleve.Index, 0, len(snap.indexes))
+	for _, idx := range snap.indexes {
+		r, known := ranges[filepath.Base(idx.Name())]
+		if !known || (!r[1].Before(from) && !r[0].After(to)) {
+			subset = append(subset, idx)
+		}
+	}
+	alias := bleve.NewIndexAlias(subset...)
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.runSearch(sctx, bc, func() (*bleve.SearchResult, error) {
+		return alias.SearchInContext(sctx, req)
+	})
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		for _, hit := range sr.Hits {
+			if result, err := s.codec.Unmarshal(hit); err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return cRes, nil
+}
+
+// manifestEntriesWithRange returns this indexer's manifest, keyed by segment basename, limited to
+// entries that have a recorded MinTime/MaxTime computed against field (see freezeSegmentTimeRange)
+// - the set SearchTimeWindow can actually use to skip a segment when searching that field. An
+// entry recorded against a different field (RangeField mismatch) is excluded exactly like one with
+// no recorded range at all, since its MinTime/MaxTime say nothing about field.
+func (s *Indexer) manifestEntriesWithRange(field string) map[string][2]time.Time {
+	out := map[string][2]time.Time{}
+	for _, e := range s.ManifestEntries() {
+		if e.MinTime.IsZero() && e.MaxTime.IsZero() {
+			continue
+		}
+		if e.RangeField != field {
+			continue
+		}
+		out[e.Path] = [2]time.Time{e.MinTime, e.MaxTime}
+	}
+	return out
+}
+
+// TimeHistogram builds a date-range facet request bucketing field into fixed-width buckets
+// of interval, covering [from, to). It saves callers from hand-computing bucket boundaries
+// for the common "counts per minute/hour" dashboard query.
+func TimeHistogram(field string, from, to time.Time, interval time.Duration) *bleve.FacetRequest {
+	fr := bleve.NewFacetRequest(field, 0)
+	for cur := from; cur.Before(to); cur = cur.Add(interval) {
+		end := cur.Add(interval)
+		if end.After(to) {
+			end = to
+		}
+		fr.AddDateTimeRange(cur.Format(time.RFC3339), cur, end)
+	}
+	return fr
+}
+
+// FindManyWithTimeHistogram runs query like FindMany but additionally attaches a
+// TimeHistogram facet on field, so the result channel carries both hits and the bucketed
+// counts (surfaced through the codec's FacetParser, if implemented).
+func (s *Indexer) FindManyWithTimeHistogram(ctx context.Context, qu interface{}, offset, limit int32, field string, from, to time.Time, interval time.Duration, customCodec dao.IndexCodex) (chan interface{}, error) {
+	if er := checkResultWindow(s.MustBleveConfig(ctx), offset, limit); er != nil {
+		return nil, er
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(qu, offset, limit, "", false)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	req.AddFacet("time_histogram", TimeHistogram(field, from, to, interval))
+
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		for _, hit := range sr.Hits {
+			if result, err := codec.Unmarshal(hit); err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+		if fParser, ok := codec.(dao.FacetParser); ok {
+			for _, facet := range sr.Facets {
+				fParser.UnmarshalFacet(facet, cRes)
+			}
+		}
+	}()
+	return cRes, nil
+}
+
+// numericFieldValue extracts a float64 out of a hit.Fields value, which comes back as
+// interface{} decoded from the index's stored JSON representation - typically float64 already,
+// but accepted as int/int64 too in case a codec stored it that way.
+func numericFieldValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// FindManyWithAggregation behaves like FindMany but additionally computes count/sum/avg/min/max
+// over field across the hits in the returned page, surfaced through the codec's
+// dao.AggregationParser if implemented. Unlike the facet helpers above, which only ever report
+// bleve's native bucket counts, this gives genuine sum/avg/min/max - but only over the page
+// actually fetched (bounded by limit), since bleve's facet API has no way to compute real
+// aggregates over the full match set. Callers who need accurate stats across a large result set
+// should drive this with FindManyAfter instead of offset/limit, accumulating across pages.
+func (s *Indexer) FindManyWithAggregation(ctx context.Context, query interface{}, offset, limit int32, sortFields string, sortDesc bool, field string, customCodec dao.IndexCodex) (chan interface{}, error) {
+	if er := checkResultWindow(s.MustBleveConfig(ctx), offset, limit); er != nil {
+		return nil, er
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(query, offset, limit, sortFields, sortDesc)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	if len(req.Fields) == 0 {
+		req.Fields = []string{field}
+	} else {
+		req.Fields = append(req.Fields, field)
+	}
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		segAware, _ := codec.(dao.SegmentAwareUnmarshaler)
+		agg := dao.AggregationResult{Field: field}
+		for _, hit := range sr.Hits {
+			if v, ok := numericFieldValue(hit.Fields[field]); ok {
+				agg.Sum += v
+				if agg.Count == 0 || v < agg.Min {
+					agg.Min = v
+				}
+				if agg.Count == 0 || v > agg.Max {
+					agg.Max = v
+				}
+				agg.Count++
+			}
+			var result interface{}
+			var err error
+			if segAware != nil {
+				result, err = segAware.UnmarshalWithSegment(hit, hit.Index)
+			} else {
+				result, err = codec.Unmarshal(hit)
+			}
+			if err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+		if agg.Count > 0 {
+			agg.Avg = agg.Sum / float64(agg.Count)
+		}
+		if aParser, ok := codec.(dao.AggregationParser); ok {
+			aParser.UnmarshalAggregation(agg)
+		}
+		if fParser, ok := codec.(dao.FacetParser); ok {
+			for _, facet := range sr.Facets {
+				fParser.UnmarshalFacet(facet, cRes)
+			}
+		}
+	}()
+	return cRes, nil
+}
+
+// FindManyWithAnalyzer behaves like FindMany but overrides the analyzer used to parse the
+// textual part of the query, instead of relying on the one selected by the field mapping.
+// It only applies to query types that support an analyzer override (query.MatchQuery and
+// query.MatchPhraseQuery); other query types are searched unchanged. The named analyzer must
+// be registered on the index mapping, otherwise an error is returned.
+func (s *Indexer) FindManyWithAnalyzer(ctx context.Context, qu interface{}, offset, limit int32, sortFields string, sortDesc bool, analyzer string, customCodec dao.IndexCodex) (chan interface{}, error) {
+	if er := checkResultWindow(s.MustBleveConfig(ctx), offset, limit); er != nil {
+		return nil, er
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(qu, offset, limit, sortFields, sortDesc)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	if analyzer != "" {
+		if s.getWriteIndex().Mapping().AnalyzerNamed(analyzer) == nil {
+			return nil, fmt.Errorf("no analyzer named '%s' registered on the mapping", analyzer)
+		}
+		switch q := req.Query.(type) {
+		case *query.MatchQuery:
+			q.Analyzer = analyzer
+		case *query.MatchPhraseQuery:
+			q.Analyzer = analyzer
+		}
+	}
+	sctx, cancel := s.deriveSearchContext(ctx)
+	sr, er := s.searchAlias(sctx, req)
+	if er != nil {
+		cancel()
+		return nil, er
+	}
+	cRes := make(chan interface{})
+	go func() {
+		defer cancel()
+		defer close(cRes)
+		for _, hit := range sr.Hits {
+			if result, err := codec.Unmarshal(hit); err == nil {
+				attachHighlightFragments(result, hit)
+				select {
+				case cRes <- result:
+				case <-sctx.Done():
+					return
+				}
+			}
+		}
+		if fParser, ok := codec.(dao.FacetParser); ok {
+			for _, facet := range sr.Facets {
+				fParser.UnmarshalFacet(facet, cRes)
+			}
+		}
+	}()
+	return cRes, nil
+}
+
+// NilMarshalCount returns the number of documents skipped because codec.Marshal returned a
+// nil document without an error.
+func (s *Indexer) NilMarshalCount() uint64 {
+	return atomic.LoadUint64(&s.nilMarshalCount)
+}
+
+// BufferFullCount returns the number of documents InsertOne or DeleteOne dropped because the
+// pending channel was saturated. See ErrIndexBufferFull and InsertBufferMode.
+func (s *Indexer) BufferFullCount() uint64 {
+	return atomic.LoadUint64(&s.bufferFullCount)
+}
+
+// InsertCount returns the number of documents received by InsertOne past its open/read-only/
+// load-shedding checks, including those later dropped for a full buffer (see BufferFullCount).
+func (s *Indexer) InsertCount() uint64 {
+	return atomic.LoadUint64(&s.insertCount)
+}
+
+// FlushCount returns the number of batches successfully committed to the write index.
+func (s *Indexer) FlushCount() uint64 {
+	return atomic.LoadUint64(&s.flushCount)
+}
+
+// FlushErrorCount returns the number of batch flushes that failed even after the
+// rotation-recovery retry. See LastError for the most recent failure.
+func (s *Indexer) FlushErrorCount() uint64 {
+	return atomic.LoadUint64(&s.flushErrorCount)
+}
+
+// RotationCount returns the number of segment rotations performed so far, whether triggered by
+// size, doc count, interval, or recovery from a flush failure.
+func (s *Indexer) RotationCount() uint64 {
+	return atomic.LoadUint64(&s.rotationCount)
+}
+
+// WriteSegmentDocCount returns the running document count of the active write segment used by
+// rotateIfNeeded's RotationDocCount trigger, for callers that want to watch how close a deployment
+// is to its configured count-based rotation threshold.
+func (s *Indexer) WriteSegmentDocCount() int64 {
+	return atomic.LoadInt64(&s.writeSegmentDocCount)
+}
+
+// CurrentBatchSize returns the number of operations staged in the current, not-yet-flushed
+// batch.
+func (s *Indexer) CurrentBatchSize() int {
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+	if s.crtBatch == nil {
+		return 0
+	}
+	return s.crtBatch.Size()
+}
+
+// SearchCount and SearchAverageLatency report how many searches have gone through searchAlias
+// and their average latency, for charting search throughput/latency over time.
+func (s *Indexer) SearchCount() uint64 {
+	return atomic.LoadUint64(&s.searchCount)
+}
+
+// SearchAverageLatency returns the average latency across all searches recorded since the last
+// ResetMetrics, or zero if none have run yet.
+func (s *Indexer) SearchAverageLatency() time.Duration {
+	count := atomic.LoadUint64(&s.searchCount)
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadUint64(&s.searchNanos) / count)
+}
+
+// IndexerMetrics is a point-in-time, value-copy snapshot of the Indexer's internal counters. Use
+// Metrics to take a snapshot and ResetMetrics to zero the counters back out, e.g. for per-interval
+// reporting.
+type IndexerMetrics struct {
+	NilMarshalCount       uint64
+	DuplicateCount        uint64
+	BufferFullCount       uint64
+	InsertCount           uint64
+	FlushCount            uint64
+	FlushErrorCount       uint64
+	RotationCount         uint64
+	ActiveSegmentDocCount int64
+	CurrentBatchSize      int
+	SearchCount           uint64
+	SearchAverageLatency  time.Duration
+	LastTTLSweepCount     int64
+	WriteUnhealthy        bool
+	LastSearchWarning     string
+}
+
+// Metrics returns a snapshot of the Indexer's internal counters.
+func (s *Indexer) Metrics() IndexerMetrics {
+	return IndexerMetrics{
+		NilMarshalCount:       atomic.LoadUint64(&s.nilMarshalCount),
+		DuplicateCount:        atomic.LoadUint64(&s.duplicateCount),
+		BufferFullCount:       atomic.LoadUint64(&s.bufferFullCount),
+		InsertCount:           s.InsertCount(),
+		FlushCount:            s.FlushCount(),
+		FlushErrorCount:       s.FlushErrorCount(),
+		RotationCount:         s.RotationCount(),
+		ActiveSegmentDocCount: s.WriteSegmentDocCount(),
+		CurrentBatchSize:      s.CurrentBatchSize(),
+		SearchCount:           s.SearchCount(),
+		SearchAverageLatency:  s.SearchAverageLatency(),
+		LastTTLSweepCount:     atomic.LoadInt64(&s.lastTTLSweepCount),
+		WriteUnhealthy:        s.writeUnhealthy,
+		LastSearchWarning:     s.LastSearchWarning(),
+	}
+}
+
+// ResetMetrics zeroes out the counters reported by Metrics, without affecting writeUnhealthy or
+// LastSearchWarning, which reflect current/last-known status rather than accumulating counts.
+func (s *Indexer) ResetMetrics() {
+	atomic.StoreUint64(&s.nilMarshalCount, 0)
+	atomic.StoreUint64(&s.duplicateCount, 0)
+	atomic.StoreUint64(&s.bufferFullCount, 0)
+	atomic.StoreUint64(&s.insertCount, 0)
+	atomic.StoreUint64(&s.flushCount, 0)
+	atomic.StoreUint64(&s.flushErrorCount, 0)
+	atomic.StoreUint64(&s.rotationCount, 0)
+	atomic.StoreUint64(&s.searchCount, 0)
+	atomic.StoreUint64(&s.searchNanos, 0)
+	atomic.StoreInt64(&s.lastTTLSweepCount, 0)
+}
+
+// SetBulkFlush configures the write path for bulk-load scenarios: batchSize (if > 0)
+// overrides the configured BatchSize to commit larger batches, and disableTimer stops the
+// periodic timer-based flush so only explicit Flush calls (or the batch-size cap) trigger a
+// commit. It must be called before Open so watchInserts picks it up when it starts.
+func (s *Indexer) SetBulkFlush(batchSize int, disableTimer bool) {
+	s.bulkBatchSize = batchSize
+	s.disableTimerFlush = disableTimer
+}
+
+// SetBulkMode toggles scorch's unsafe-batch mode for indexes created after this call: writes are
+// no longer acknowledged to the caller only once durably persisted, which significantly speeds up
+// bulk loads (e.g. Resync) at the cost of crash safety mid-load. Scorch still performs a single
+// durable persist of everything written so far when the index is Closed, so a clean shutdown is
+// safe; but if the process crashes while bulk-loading, the partially-written index must be
+// discarded and the bulk load restarted from scratch. It only affects indexes that do not yet
+// exist on disk, so it must be called before Open/openOneIndex creates the index it should apply
+// to (see Resync, which applies it to the freshly created copy it builds).
+func (s *Indexer) SetBulkMode(bulk bool) {
+	s.bulkMode = bulk
+}
+
+// SetStoreConfig registers store-level options merged into the kvConfig map passed to
+// bleve.NewUsing for every index created from now on, the same way SetBulkMode's "unsafe_batch"
+// is merged in (bulk mode wins if both set the same key). It only affects indexes that do not yet
+// exist on disk, so like SetBulkMode it must be called before Open/openOneIndex creates the index
+// it should apply to.
+//
+// Note: the vendored bleve/zapx version this indexer runs does not expose a segment-level
+// compression or chunk-factor knob through this path - kvConfig configures the backend
+// (boltdb/goleveldb), not segment encoding - so this cannot trade CPU for disk today. It exists as
+// the extension point for whatever the backend already accepts (e.g. "bolt_timeout",
+// "numSnapshotsToKeep"), and for a future bleve/zapx upgrade that adds one.
+func (s *Indexer) SetStoreConfig(cfg map[string]interface{}) {
+	s.storeConfig = cfg
+}
+
+// SetNameForRotation replaces the built-in zero-padded "<base>.NNNN" scheme used to name and
+// recognize rotated segments, letting a caller use something else - e.g. date-based directory
+// names such as "logs-2024-06-01" for easier offsite archiving. name computes a segment's
+// directory name from its base path, its position (0 for the oldest/original segment, sharing
+// base's own name, incrementing from there) and its creation time; match must recognize every
+// name name can produce, given a candidate directory's basename and base, or listIndexes's
+// directory scan silently stops finding segments created under the new scheme. Either argument
+// left nil keeps the corresponding half of the default behavior. Like SetBulkMode, it only
+// affects segments created (or renumbered, see listIndexes) after the call, so it must be called
+// before Open.
+func (s *Indexer) SetNameForRotation(name func(base string, seq int, t time.Time) string, match func(curBase, base string) bool) {
+	s.nameForRotation = name
+	s.segmentNameMatcher = match
+}
+
+// rotationName is rotateIfNeeded/forceRotate/listIndexes's single entry point for computing a
+// segment's directory name, delegating to s.nameForRotation when SetNameForRotation configured
+// one, and otherwise reproducing the original zero-padded "<base>.NNNN" scheme.
+func (s *Indexer) rotationName(base string, seq int, t time.Time) string {
+	if s.nameForRotation != nil {
+		return s.nameForRotation(base, seq, t)
+	}
+	if seq == 0 {
+		return base
+	}
+	return fmt.Sprintf("%s.%04d", base, seq)
+}
+
+// matchSegmentName is listIndexes/recoverInterruptedRenumbering's single entry point for
+// recognizing a directory as a segment of base, delegating to s.segmentNameMatcher when
+// SetNameForRotation configured one, and otherwise falling back to isSegmentName.
+func (s *Indexer) matchSegmentName(curBase, base string) bool {
+	if s.segmentNameMatcher != nil {
+		return s.segmentNameMatcher(curBase, base)
+	}
+	return isSegmentName(curBase, base)
+}
+
+func (s *Indexer) SetCodex(c dao.IndexCodex) {
+	s.codec = c
+}
+
+// WriteIndexStrategy picks which segment inserts/deletes should be staged against, given the
+// current list of open segments and the cursor (the newest segment, i.e. the current default
+// write target). It must return a valid index into indexes.
+type WriteIndexStrategy func(indexes []bleve.Index, cursor int) int
+
+// newestSegmentStrategy is the default WriteIndexStrategy: always write to the most recently
+// rotated segment.
+func newestSegmentStrategy(_ []bleve.Index, cursor int) int {
+	return cursor
+}
+
+// SetWriteIndexStrategy overrides how getWriteIndex picks a segment to write to, e.g. routing by
+// remaining size or round-robin across segments for write parallelism on high ingest rates. It
+// must be called before Open.
+//
+// Consistency implications: rotateIfNeeded only watches the disk usage of the segment at
+// s.cursor, so a strategy that writes elsewhere will not trigger timely rotation of the segments
+// it actually fills. Searches are unaffected, since FindMany always queries the alias covering
+// every open segment regardless of which one is currently being written to.
+func (s *Indexer) SetWriteIndexStrategy(strategy WriteIndexStrategy) {
+	s.writeIndexStrategy = strategy
+}
+
+func (s *Indexer) getWriteIndex() bleve.Index {
+	snap := s.snapshotSegments()
+	if snap.cursor == -1 || len(snap.indexes) < snap.cursor-1 {
+		// Use a no-op, in-memory index to avoid crashes
+		s.logPrintln("[ERROR] Cannot find an available index for writing, entries will be logged in memory")
+		s.logPrintln("[ERROR] This should not happen and may indicate a missing MaxConcurrency=1 on the Truncate Logs flow.")
+		s.logPrintln("[ERROR] Make sure to fix it and restart if necessary.")
+		idx, _ := s.openOneIndex("", s.MustBleveConfig(context.Background()).MappingName)
+		return idx
+	}
+	strategy := s.writeIndexStrategy
+	if strategy == nil {
+		strategy = newestSegmentStrategy
+	}
+	idx := strategy(snap.indexes, snap.cursor)
+	if idx < 0 || idx >= len(snap.indexes) {
+		idx = snap.cursor
+	}
+	return snap.indexes[idx]
+}
+
+// isSegmentName reports whether curBase is a valid segment name for base: either base itself (the
+// active write segment) or base followed by a numeric ".NNNN" rotation suffix.
+func isSegmentName(curBase, base string) bool {
+	if curBase == base {
+		return true
+	}
+	if !strings.HasPrefix(curBase, base+".") {
+		return false
+	}
+	test := strings.TrimLeft(strings.TrimPrefix(curBase, base+"."), "0")
+	_, e := strconv.ParseInt(test, 10, 32)
+	return e == nil
+}
+
+// recoverInterruptedRenumbering finishes a listIndexes renumbering pass that crashed between its
+// two rename phases, leaving one or more segments stuck under "<name>-rename" instead of their
+// final name. Left alone, those segments are invisible to listIndexes's normal scan (they match
+// neither base nor base.NNNN) and would be silently abandoned on every subsequent start - this is
+// what stranded a node that crashed mid-Truncate. It is idempotent and safe to call on every
+// Open: when nothing was left stuck, it finds no "-rename" entries and does nothing.
+func (s *Indexer) recoverInterruptedRenumbering() {
+	dirPath, base := filepath.Split(s.indexPath)
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		return
+	}
+	var done []string
+	var pending []string
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		name := filepath.Base(file.Name())
+		if orig := strings.TrimSuffix(name, "-rename"); orig != name {
+			if s.matchSegmentName(orig, base) {
+				pending = append(pending, name)
+			}
+			continue
+		}
+		if s.matchSegmentName(name, base) && isBleveIndexDir(filepath.Join(dirPath, name)) {
+			done = append(done, name)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	s.logPrintln(fmt.Sprintf("[pydio.grpc.log] Recovering %d segment(s) left mid-renumbering by a previous crash", len(pending)))
+	// The "-rename" suffix was applied to paths already sorted into their intended final order
+	// (see listIndexes), so sorting on the name with the suffix stripped recovers that order.
+	sort.Slice(pending, func(i, j int) bool {
+		return strings.TrimSuffix(pending[i], "-rename") < strings.TrimSuffix(pending[j], "-rename")
+	})
+	manifest := s.loadManifest()
+	next := len(done)
+	for _, p := range pending {
+		src := filepath.Join(dirPath, p)
+		createdAt := time.Now()
+		if e, ok := manifest[strings.TrimSuffix(p, "-rename")]; ok {
+			createdAt = e.CreatedAt
+		}
+		dst := filepath.Join(dirPath, s.rotationName(base, next, createdAt))
+		if err := os.Rename(src, dst); err != nil {
+			s.logPrintln("[pydio.grpc.log] Could not finish recovering segment", p, err)
+			continue
+		}
+		next++
+	}
+}
+
+func (s *Indexer) listIndexes(renameIfNeeded ...bool) (paths []string) {
+	dirPath, base := filepath.Split(s.indexPath)
+
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		curBase := filepath.Base(file.Name())
+		if s.matchSegmentName(curBase, base) && isBleveIndexDir(filepath.Join(dirPath, curBase)) {
+			paths = append(paths, curBase)
+		}
+	}
+	sort.Strings(paths)
+	if len(renameIfNeeded) > 0 && renameIfNeeded[0] && len(paths) > 0 && paths[0] != base {
+		// Old files were removed, renumber files
+		manifest := s.loadManifest()
+		for _, p := range paths {
+			src := filepath.Join(dirPath, p)
+			t1 := filepath.Join(dirPath, fmt.Sprintf("%s-rename", p))
+			os.Rename(src, t1)
+		}
+		for i, p := range paths {
+			src := filepath.Join(dirPath, fmt.Sprintf("%s-rename", p))
+			createdAt := time.Now()
+			if e, ok := manifest[p]; ok {
+				createdAt = e.CreatedAt
+			}
+			t2 := filepath.Join(dirPath, s.rotationName(base, i, createdAt))
+			os.Rename(src, t2)
+		}
+		return s.listIndexes()
+	}
+	return
+}
+
+// isBleveIndexDir reports whether path looks like an actual bleve index directory rather than an
+// unrelated directory that happens to match the segment naming pattern (e.g. a backup or a
+// `.trash` folder dropped next to the index). It checks for bleve's own "index_meta.json" marker
+// file instead of trusting the name alone, so listIndexes doesn't try to open - and fail on -
+// directories that were never bleve indexes.
+func isBleveIndexDir(path string) bool {
+	_, err := os.Stat(filepath.Join(path, "index_meta.json"))
+	return err == nil
+}
+
+// indexInline marshals and indexes a single document directly against the current write index,
+// bypassing the inserts channel, crtBatch staging and the background flush cadence. It backs
+// SynchronousMode: a single Index() call commits immediately, so callers can search for the
+// document as soon as InsertOne returns.
+func (s *Indexer) indexInline(ctx context.Context, data interface{}) error {
+	bc := s.MustBleveConfig(ctx)
+	msg, er := s.codec.Marshal(data)
+	if er != nil {
+		return er
+	}
+	if msg == nil {
+		atomic.AddUint64(&s.nilMarshalCount, 1)
+		return nil
+	}
+	if bc.StoreSource {
+		s.attachSource(msg, data)
+	}
+	var id string
+	if provider, ok := msg.(dao.IndexIDProvider); ok {
+		id = provider.IndexID()
+	} else {
+		id = xid.New().String()
+	}
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+	if err := s.getWriteIndex().Index(id, msg); err != nil {
+		return err
+	}
+	atomic.AddInt64(&s.writeSegmentDocCount, 1)
+	s.teeToShadows(id, msg)
+	return nil
+}
+
+func (s *Indexer) watchInserts() {
+	bc := s.MustBleveConfig(context.Background())
+	batchSize := int(bc.BatchSize)
+	if s.bulkBatchSize > 0 {
+		batchSize = s.bulkBatchSize
+	}
+	flushInterval := bc.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		atomic.StoreInt64(&s.lastWatchHeartbeat, time.Now().UnixNano())
+		select {
+		case env := <-s.inserts:
+			s.processInsertEnvelope(env, batchSize, bc)
+		case <-s.forceFlush:
+			s.flushLock.Lock()
+			s.flush()
+			s.flushLock.Unlock()
+		case done := <-s.flushSync:
+			s.flushLock.Lock()
+			s.flush()
+			err := s.LastError()
+			s.flushLock.Unlock()
+			done <- err
+		case <-ticker.C:
+			if s.disableTimerFlush {
+				break
+			}
+			s.flushLock.Lock()
+			s.flush()
+			s.flushLock.Unlock()
+		case <-s.insertsDone:
+			// Close no longer closes s.inserts, so whatever InsertOne/DeleteOne already enqueued
+			// before (or racing with) the shutdown signal is still sitting in the channel rather
+			// than lost: drain it into the final batch before flushing and closing segments.
+		drain:
+			for {
+				select {
+				case env := <-s.inserts:
+					s.processInsertEnvelope(env, batchSize, bc)
+				default:
+					break drain
+				}
+			}
+			s.flushLock.Lock()
+			s.flush()
+			s.flushLock.Unlock()
+			snap := s.snapshotSegments()
+			snap.searchIndex.Close()
+			for _, i := range snap.indexes {
+				i.Close()
+			}
+			close(s.closedDone)
+			return
+		}
+	}
+}
+
+// processInsertEnvelope applies a single insert or delete envelope to crtBatch, flushing once
+// batchSize or FlushEveryN is reached. Factored out of watchInserts' main select loop so the
+// final drain it runs against s.inserts on shutdown (see the insertsDone case) can reuse the
+// exact same logic instead of duplicating it.
+func (s *Indexer) processInsertEnvelope(env interface{}, batchSize int, bc *BleveConfig) {
+	if wrapped, ok := env.(insertEnvelope); ok && wrapped.delete {
+		del, ctx := s.unwrapEnvelope(env)
+		if id, o := del.(string); o {
+			s.flushLock.Lock()
+			s.batchCtx = ctx
+			if s.crtBatch == nil {
+				s.crtBatch = s.getWriteIndex().NewBatch()
+				s.crtBatchIDs = make(map[string]bool)
+			}
+			s.crtBatch.Delete(id)
+			s.stagedSinceFlush++
+			if s.crtBatch.Size() >= batchSize || (bc.FlushEveryN > 0 && s.stagedSinceFlush >= bc.FlushEveryN) {
+				s.flush()
+			}
+			s.flushLock.Unlock()
+		}
+		return
+	}
+	in, ctx := s.unwrapEnvelope(env)
+	var msg interface{}
+	if wrapped, ok := env.(insertEnvelope); ok && wrapped.marshalled {
+		// Already marshalled (and StoreSource-attached) eagerly in InsertOne: see EagerMarshal.
+		msg = in
+	} else {
+		var er error
+		msg, er = s.codec.Marshal(in)
+		if er != nil {
+			return
+		}
+		if msg == nil {
+			// A codec bug returning (nil, nil) must not silently become an empty indexed
+			// document: treat it as an error and count it instead.
+			atomic.AddUint64(&s.nilMarshalCount, 1)
+			log.Logger(ctx).Warn("[pydio.grpc.log] codec.Marshal returned a nil document without error, skipping")
+			return
+		}
+		if bc.StoreSource {
+			s.attachSource(msg, in)
+		}
+	}
+	s.flushLock.Lock()
+	s.batchCtx = ctx
+	if s.crtBatch == nil {
+		s.crtBatch = s.getWriteIndex().NewBatch()
+		s.crtBatchIDs = make(map[string]bool)
+	}
+	var id string
+	if provider, ok := msg.(dao.IndexIDProvider); ok {
+		id = provider.IndexID()
+	} else {
+		id = xid.New().String()
+	}
+	if s.crtBatchIDs[id] {
+		switch bc.DuplicatePolicy {
+		case DuplicatePolicyFirstWins:
+			atomic.AddUint64(&s.duplicateCount, 1)
+		case DuplicatePolicyError:
+			atomic.AddUint64(&s.duplicateCount, 1)
+			log.Logger(ctx).Error("[pydio.grpc.log] duplicate id within batch rejected: " + id)
+		default: // DuplicatePolicyLastWins
+			s.crtBatch.Index(id, msg)
+			s.stagedSinceFlush++
+			if s.crtBatch.Size() >= batchSize || (bc.FlushEveryN > 0 && s.stagedSinceFlush >= bc.FlushEveryN) {
+				s.flush()
+			}
+		}
+		s.flushLock.Unlock()
+		return
+	}
+	s.crtBatchIDs[id] = true
+	s.crtBatch.Index(id, msg)
+	atomic.AddInt64(&s.writeSegmentDocCount, 1)
+	s.stagedSinceFlush++
+	if s.crtBatch.Size() >= batchSize || (bc.FlushEveryN > 0 && s.stagedSinceFlush >= bc.FlushEveryN) {
+		s.flush()
+	}
+	s.flushLock.Unlock()
+	s.teeToShadows(id, msg)
+}
+
+// MaxStoredBinaryFieldSize caps the size of a value passed to EncodeBinaryField, to keep a
+// single document reasonable in size since stored fields live alongside the index data.
+const MaxStoredBinaryFieldSize = 1 << 20 // 1MB
+
+// NewStoredBinaryFieldMapping returns a field mapping for a stored-only binary blob: it is kept
+// verbatim for retrieval (Store) but never analyzed or searched (Index false), so bleve does not
+// attempt to tokenize it. Codecs should use it in their GetModel document mapping for any field
+// holding binary data (e.g. a serialized protobuf), and encode/decode the value with
+// EncodeBinaryField/DecodeBinaryField.
+func NewStoredBinaryFieldMapping() *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Index = false
+	fm.Store = true
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	return fm
+}
+
+// EncodeBinaryField encodes a binary value for storage in a field mapped with
+// NewStoredBinaryFieldMapping, rejecting values over MaxStoredBinaryFieldSize.
+func EncodeBinaryField(data []byte) (string, error) {
+	if len(data) > MaxStoredBinaryFieldSize {
+		return "", fmt.Errorf("binary field value of %d bytes exceeds the %d bytes limit", len(data), MaxStoredBinaryFieldSize)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecodeBinaryField reverses EncodeBinaryField, returning the original bytes stored in a field
+// mapped with NewStoredBinaryFieldMapping.
+func DecodeBinaryField(stored string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(stored)
+}
+
+// attachSource stores the original, unmarshalled document as a "_source" field on the
+// marshalled message so it can be retrieved verbatim, regardless of what the mapping keeps.
+// It is best-effort: it only applies when the codec marshals documents to a
+// map[string]interface{}, since arbitrary struct types have no generic extension point.
+func (s *Indexer) attachSource(msg interface{}, original interface{}) {
+	mp, ok := msg.(map[string]interface{})
+	if !ok {
+		return
+	}
+	raw, er := json.Marshal(original)
+	if er != nil {
+		return
+	}
+	mp["_source"] = string(raw)
+}
+
+func (s *Indexer) rotateIfNeeded() {
+	bc := s.MustBleveConfig(context.Background())
+
+	if s.indexPath == "" || bc.RotationSize == -1 {
+		return
+	}
+	if atomic.LoadInt32(&s.rotationPaused) != 0 {
+		return
+	}
+	if bc.RotationWarmup > 0 && time.Since(s.openedAt) < bc.RotationWarmup {
+		return
+	}
+	snap := s.snapshotSegments()
+	checkPath := s.indexPath
+	if snap.cursor > 0 {
+		checkPath = snap.indexes[snap.cursor].Name()
+	}
+	du, e := indexDiskUsage(checkPath)
+	if e != nil {
+		s.logPrintln("[pydio.grpc.log] Cannot compute disk usage for bleve index", e.Error())
+		return
+	}
+	s.setSegmentDiskUsage(checkPath, du)
+	rotateForSize := du > bc.RotationSize
+	rotateForDocCount := bc.RotationDocCount > 0 && atomic.LoadInt64(&s.writeSegmentDocCount) >= bc.RotationDocCount
+	rotateForInterval := bc.RotationInterval > 0 && time.Since(s.writeSegmentOpenedAt) >= bc.RotationInterval
+	if rotateForSize || rotateForDocCount || rotateForInterval {
+		reason := "size"
+		if rotateForInterval && !rotateForSize && !rotateForDocCount {
+			reason = "age"
+			s.logPrintln("Rotating "+s.indexPath+" for age ", time.Since(s.writeSegmentOpenedAt))
+		} else if rotateForDocCount && !rotateForSize {
+			reason = "doc count"
+			s.logPrintln("Rotating "+s.indexPath+" for doc count ", atomic.LoadInt64(&s.writeSegmentDocCount))
+		} else {
+			s.logPrintln("Rotating "+s.indexPath+" for size ", du)
+		}
+		// Open a new index
+		newPath := s.rotationName(s.indexPath, len(snap.indexes), time.Now())
+		newIndex, er := s.openOneIndex(newPath, bc.MappingName)
+		if er != nil {
+			s.logPrintln("[pydio.grpc.log] Cannot create new bleve index", er.Error())
+			return
+		}
+		s.recordManifestSegment(newPath, reason)
+		s.freezeSegmentTimeRange(context.Background(), snap.indexes[snap.cursor], checkPath, bc)
+		if s.onRotate != nil {
+			s.onRotate(checkPath, newPath)
+		}
+		s.segLock.Lock()
+		s.indexes = append(s.indexes, newIndex)
+		s.cursor = len(s.indexes) - 1
+		alias := s.searchIndex
+		s.segLock.Unlock()
+		s.setSegmentDiskUsage(newPath, 0)
+		if bc.AliasRefreshInterval > 0 {
+			// Defer making the new segment searchable until the next alias refresh tick.
+			s.aliasLock.Lock()
+			s.pendingAlias = append(s.pendingAlias, newIndex)
+			s.aliasLock.Unlock()
+		} else {
+			alias.Add(newIndex)
+		}
+		atomic.StoreInt64(&s.writeSegmentDocCount, 0)
+		s.writeSegmentOpenedAt = time.Now()
+		atomic.AddUint64(&s.rotationCount, 1)
+	}
+
+	s.updateStatus()
+}
+
+// refreshAlias adds any segment rotated since the last tick to the search alias. It is only
+// started when AliasRefreshInterval is configured.
+func (s *Indexer) refreshAlias() {
+	s.aliasLock.Lock()
+	pending := s.pendingAlias
+	s.pendingAlias = nil
+	s.aliasLock.Unlock()
+	if len(pending) > 0 {
+		s.snapshotSegments().searchIndex.Add(pending...)
+	}
+}
+
+// watchAliasRefresh periodically flushes pendingAlias into the search alias until the
+// indexer is closed.
+func (s *Indexer) watchAliasRefresh(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.refreshAlias()
+		case <-s.insertsDone:
+			s.refreshAlias()
+			return
+		}
+	}
+}
+
+// watchTTLSweep periodically deletes documents whose TTLField value is in the past, in bounded
+// batches, so expiration never competes hard with live ingest/search.
+func (s *Indexer) watchTTLSweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.sweepExpired(context.Background()); err != nil {
+				s.logPrintln("[pydio.grpc.log] TTL sweep error:", err.Error())
+			}
+		case <-s.insertsDone:
+			return
+		}
+	}
+}
+
+// LastTTLSweepCount returns the number of documents deleted by the most recently completed TTL
+// sweep, or 0 if TTL sweeping is not configured or has not run yet.
+func (s *Indexer) LastTTLSweepCount() int64 {
+	return atomic.LoadInt64(&s.lastTTLSweepCount)
+}
+
+// sweepExpired deletes documents whose TTLField value is before now, across all open segments,
+// in batches of TTLSweepBatchSize with a TTLSweepPause between batches to keep the sweep gentle
+// on a busy index. It returns the total number of documents deleted.
+func (s *Indexer) sweepExpired(ctx context.Context) (int64, error) {
+	bc := s.MustBleveConfig(ctx)
+	if bc.TTLField == "" {
+		return 0, nil
+	}
+	batchSize := bc.TTLSweepBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultTTLSweepBatchSize
+	}
+	var total int64
+	for _, idx := range s.snapshotSegments().indexes {
+		for {
+			dateQuery := bleve.NewDateRangeQuery(time.Time{}, time.Now())
+			dateQuery.SetField(bc.TTLField)
+			req := bleve.NewSearchRequest(dateQuery)
+			req.Size = batchSize
+			sr, err := idx.SearchInContext(ctx, req)
+			if err != nil {
+				return total, err
+			}
+			if len(sr.Hits) == 0 {
+				break
+			}
+			b := idx.NewBatch()
+			for _, hit := range sr.Hits {
+				b.Delete(hit.ID)
+			}
+			if err := idx.Batch(b); err != nil {
+				return total, err
+			}
+			total += int64(len(sr.Hits))
+			atomic.StoreInt64(&s.lastTTLSweepCount, total)
+			if len(sr.Hits) < batchSize {
+				break
+			}
+			if bc.TTLSweepPause > 0 {
+				time.Sleep(bc.TTLSweepPause)
+			}
+		}
+	}
+	return total, nil
+}
+
+// watchRetention periodically runs sweepOldSegments until the indexer is closed.
+func (s *Indexer) watchRetention(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.sweepOldSegments(context.Background()); err != nil {
+				s.logPrintln("[pydio.grpc.log] Retention sweep error:", err.Error())
+			}
+		case <-s.insertsDone:
+			return
+		}
+	}
+}
+
+// removeRotatedSegment unlinks idx from the live segment list, search alias and manifest, then
+// closes and deletes it from disk. idx must not be the active write segment: callers are
+// responsible for never offering it up for removal. The current write segment is re-resolved
+// from live state under segLock right before idx is unlinked, rather than from an identity the
+// caller captured earlier against a point-in-time snapshot - a concurrent rotation between that
+// snapshot and this call would otherwise leave s.cursor pointing at a segment that has since
+// stopped being the write target. label is only used for the log line (e.g. "expired segment",
+// "segment over retention policy").
+func (s *Indexer) removeRotatedSegment(idx bleve.Index, label string) {
+	segPath := idx.Name()
+	s.flushLock.Lock()
+	s.segLock.Lock()
+	var writeIndex bleve.Index
+	if s.cursor >= 0 && s.cursor < len(s.indexes) {
+		writeIndex = s.indexes[s.cursor]
+	}
+	kept := s.indexes[:0:0]
+	for _, i := range s.indexes {
+		if i != idx {
+			kept = append(kept, i)
+		}
+	}
+	s.indexes = kept
+	s.cursor = -1
+	for j, i := range s.indexes {
+		if i == writeIndex {
+			s.cursor = j
+			break
+		}
+	}
+	s.searchIndex.Remove(idx)
+	s.segLock.Unlock()
+	s.flushLock.Unlock()
+	if err := idx.Close(); err != nil {
+		s.logPrintln("[pydio.grpc.log] Retention: error closing "+label, segPath, err.Error())
+	}
+	if err := os.RemoveAll(segPath); err != nil {
+		s.logPrintln("[pydio.grpc.log] Retention: error removing "+label, segPath, err.Error())
+		return
+	}
+	s.removeManifestSegment(segPath)
+	s.logPrintln("[pydio.grpc.log] Retention: removed "+label, segPath)
+}
+
+// freezeSegmentTimeRange computes idx's document time range on RetentionField (falling back to
+// TimeField, the same fallback sweepOldSegments uses) and records it into path's manifest entry,
+// so SearchTimeWindow can later skip this segment by reading the manifest instead of opening it.
+// It is only ever called once, right after idx stops being the write target and becomes
+// immutable, so the computed range stays valid for the rest of the segment's life. A missing time
+// field, or any error reading it, is silently skipped: the segment is simply left without a
+// recorded range, which SearchTimeWindow treats as "always include" rather than as a failure.
+func (s *Indexer) freezeSegmentTimeRange(ctx context.Context, idx bleve.Index, path string, bc *BleveConfig) {
+	field := bc.RetentionField
+	if field == "" {
+		field = bc.TimeField
+	}
+	if field == "" {
+		return
+	}
+	min, ok := segmentFieldExtreme(ctx, idx, field, false)
+	if !ok {
+		return
+	}
+	max, ok := segmentFieldExtreme(ctx, idx, field, true)
+	if !ok {
+		return
+	}
+	s.recordManifestTimeRange(path, field, min, max)
+}
+
+// segmentFieldExtreme reads the oldest (desc false) or newest (desc true) value of field across
+// idx via a single sorted, one-hit search - the same bounded-search-per-segment technique
+// sweepOldSegments uses to read a segment's newest RetentionField value.
+func segmentFieldExtreme(ctx context.Context, idx bleve.Index, field string, desc bool) (time.Time, bool) {
+	req := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+	req.Size = 1
+	req.Fields = []string{field}
+	sortField := field
+	if desc {
+		sortField = "-" + field
+	}
+	req.SortBy([]string{sortField})
+	sr, err := idx.SearchInContext(ctx, req)
+	if err != nil || sr == nil || len(sr.Hits) == 0 {
+		return time.Time{}, false
+	}
+	raw, ok := sr.Hits[0].Fields[field].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// sweepOldSegments removes rotated segments (never the active write segment) whose newest
+// RetentionField value is older than RetentionMaxAge. Segment directory names carry no timestamp
+// of their own, so age is determined by sorting each candidate segment on RetentionField
+// descending and reading the top hit - a small, bounded search per segment rather than a full
+// scan.
+func (s *Indexer) sweepOldSegments(ctx context.Context) error {
+	bc := s.MustBleveConfig(ctx)
+	if bc.RetentionMaxAge <= 0 || s.indexPath == "" {
+		return nil
+	}
+	field := bc.RetentionField
+	if field == "" {
+		field = bc.TimeField
+	}
+	// Falling back to the manifest's recorded CreatedAt avoids the per-segment document search
+	// below entirely when no document time field is configured: segment age becomes a manifest
+	// lookup instead of a scan.
+	manifest := s.ManifestEntries()
+	if field == "" && len(manifest) == 0 {
+		return fmt.Errorf("bleve: RetentionMaxAge is set but neither RetentionField nor TimeField is configured, and no segment manifest is available")
+	}
+	cutoff := time.Now().Add(-bc.RetentionMaxAge)
+	manifestCreatedAt := make(map[string]time.Time, len(manifest))
+	for _, e := range manifest {
+		manifestCreatedAt[e.Path] = e.CreatedAt
+	}
+
+	s.flushLock.Lock()
+	snap := s.snapshotSegments()
+	s.flushLock.Unlock()
+	candidates := make([]bleve.Index, 0, len(snap.indexes)-1)
+	for i, idx := range snap.indexes {
+		if i != snap.cursor {
+			candidates = append(candidates, idx)
+		}
+	}
+
+	for _, idx := range candidates {
+		var newest time.Time
+		if field == "" {
+			createdAt, ok := manifestCreatedAt[filepath.Base(idx.Name())]
+			if !ok {
+				continue
+			}
+			newest = createdAt
+		} else {
+			req := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+			req.Size = 1
+			req.Fields = []string{field}
+			req.SortBy([]string{"-" + field})
+			sr, err := idx.SearchInContext(ctx, req)
+			if err != nil {
+				s.logPrintln("[pydio.grpc.log] Retention: cannot check age of segment", idx.Name(), err.Error())
+				continue
+			}
+			if len(sr.Hits) == 0 {
+				continue
+			}
+			raw, ok := sr.Hits[0].Fields[field].(string)
+			if !ok {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				continue
+			}
+			newest = parsed
+		}
+		if newest.After(cutoff) {
+			continue
+		}
+		s.removeRotatedSegment(idx, "expired segment")
+	}
+	s.refreshTotalDiskUsage()
+	return nil
+}
+
+// flush commits the current batch to the write index. If the write index is persistently
+// failing (e.g. it was closed or corrupted by a previous failed rotation), it attempts to
+// recover by opening a fresh segment before giving up and marking the indexer unhealthy.
+func (s *Indexer) flush() {
+	if s.crtBatch == nil {
+		return
+	}
+	ctx := s.batchCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	count := s.crtBatch.Size()
+	if err := s.getWriteIndex().Batch(s.crtBatch); err != nil {
+		log.Logger(ctx).Warn("[pydio.grpc.log] Error while flushing batch, trying to recover with a fresh segment: " + err.Error())
+		s.forceRotate()
+		if err = s.getWriteIndex().Batch(s.crtBatch); err != nil {
+			log.Logger(ctx).Error("[pydio.grpc.log] Could not recover write index, keeping batch for retry on next flush: " + err.Error())
+			s.writeUnhealthy = true
+			s.recordFlushError(err)
+			atomic.AddUint64(&s.flushErrorCount, 1)
+			return
+		}
+	}
+	s.writeUnhealthy = false
+	s.clearFlushError()
+	atomic.AddUint64(&s.flushCount, 1)
+	if s.onFlush != nil {
+		s.onFlush(count)
+	}
+	s.rotateIfNeeded()
+	s.crtBatch = nil
+	s.crtBatchIDs = nil
+	s.stagedSinceFlush = 0
+}
+
+// SetOnRotate registers a callback invoked from rotateIfNeeded right after a new write segment is
+// created, receiving the path of the segment just closed off from further writes and the path of
+// the new one (e.g. to trigger an external backup of the former). It must be called before Open
+// to catch the first rotation.
+func (s *Indexer) SetOnRotate(fn func(closedPath, newPath string)) {
+	s.onRotate = fn
+}
+
+// SetOnFlush registers a callback invoked from flush after a batch is successfully written to the
+// write index, receiving the number of operations the batch contained. It must be called before
+// Open to catch the first flush.
+func (s *Indexer) SetOnFlush(fn func(count int)) {
+	s.onFlush = fn
+}
+
+// LastError returns the most recent error encountered while flushing a batch, or nil if the
+// last flush succeeded (or none has been attempted yet).
+func (s *Indexer) LastError() error {
+	s.lastFlushErrLock.Lock()
+	defer s.lastFlushErrLock.Unlock()
+	return s.lastFlushErr
+}
+
+// SetLogger routes the package's internal diagnostics (segment open failures, rotation
+// decisions, recovery attempts, ...) to fn instead of stdout. It must be called before Open to
+// catch the lines logged while opening.
+func (s *Indexer) SetLogger(fn func(msg string)) {
+	s.logger = fn
+}
+
+// logPrintln formats args exactly like fmt.Println (space-separated, no trailing newline kept)
+// and routes them to the configured logger, falling back to fmt.Println when none is set.
+func (s *Indexer) logPrintln(args ...interface{}) {
+	if s.logger == nil {
+		fmt.Println(args...)
+		return
+	}
+	s.logger(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// SetOnFlushFailure registers a callback invoked once consecutive flush failures reach
+// BleveConfig.FlushFailureThreshold, so the embedding service can alarm on persistent flush
+// failures instead of discovering the data loss later. It must be called before Open to be
+// picked up by flush().
+func (s *Indexer) SetOnFlushFailure(fn func(err error, consecutiveFailures int)) {
+	s.onFlushFailure = fn
+}
+
+// recordFlushError retains err for LastError, bumps consecutiveFlushFailures and fires
+// onFlushFailure once the configured threshold is reached.
+func (s *Indexer) recordFlushError(err error) {
+	s.lastFlushErrLock.Lock()
+	s.lastFlushErr = err
+	s.lastFlushErrLock.Unlock()
+	n := atomic.AddInt32(&s.consecutiveFlushFailures, 1)
+	bc := s.MustBleveConfig(context.Background())
+	if s.onFlushFailure != nil && bc.FlushFailureThreshold > 0 && int(n) >= bc.FlushFailureThreshold {
+		s.onFlushFailure(err, int(n))
+	}
+}
+
+// clearFlushError resets the flush failure state after a successful flush.
+func (s *Indexer) clearFlushError() {
+	s.lastFlushErrLock.Lock()
+	s.lastFlushErr = nil
+	s.lastFlushErrLock.Unlock()
+	atomic.StoreInt32(&s.consecutiveFlushFailures, 0)
+}
+
+// SetOnCorruptSegment registers a callback invoked whenever Open quarantines a segment it could
+// not open. It must be called before Open to catch segments quarantined while opening.
+func (s *Indexer) SetOnCorruptSegment(fn func(path string, cause error)) {
+	s.onCorruptSegment = fn
+}
+
+// quarantineSegment moves a segment directory that failed to open aside with a unique
+// "-corrupt-<id>" suffix, so it stops blocking future Open calls and is preserved for inspection
+// instead of silently lost, and notifies onCorruptSegment if one is registered. It does not
+// attempt to rebuild the segment: unlike a replicated store, a bleve segment holds documents no
+// other segment has a copy of, so recovering the lost data means falling back to whatever
+// backup exists outside the indexer (see Backup/RestoreSegment).
+func (s *Indexer) quarantineSegment(path string, cause error) {
+	quarantinePath := path + "-corrupt-" + uuid.New()
+	if err := os.Rename(path, quarantinePath); err != nil {
+		s.logPrintln("[pydio.grpc.log] Could not quarantine corrupt segment", path, err.Error())
+		return
+	}
+	s.logPrintln("[pydio.grpc.log] Quarantined corrupt segment", path, "->", quarantinePath)
+	if s.onCorruptSegment != nil {
+		s.onCorruptSegment(path, cause)
+	}
+}
+
+// Verify opens every segment directory currently on disk via bleve.Open - not s.openOneIndex,
+// which would silently create a fresh empty index for a path that doesn't exist or isn't a valid
+// bleve index - and reports the names of the ones that fail to open, without touching the live
+// s.indexes. Run it periodically, or before trusting search results, to catch segment corruption
+// that Open's per-segment quarantine may not have caught yet (e.g. a segment that was still
+// readable at Open time but has since degraded).
+func (s *Indexer) Verify() ([]string, error) {
+	if s.indexPath == "" {
+		return nil, nil
+	}
+	var broken []string
+	for _, name := range s.listIndexes() {
+		path := filepath.Join(filepath.Dir(s.indexPath), name)
+		idx, err := bleve.Open(path)
+		if err != nil {
+			broken = append(broken, name)
+			continue
+		}
+		idx.Close()
+	}
+	return broken, nil
+}
+
+// forceRotate opens a brand new segment and makes it the write index, regardless of the
+// configured rotation thresholds. It is used as a recovery path when the current write
+// index starts failing.
+func (s *Indexer) forceRotate() {
+	if s.indexPath == "" {
+		return
+	}
+	bc := s.MustBleveConfig(context.Background())
+	newPath := s.rotationName(s.indexPath, len(s.snapshotSegments().indexes), time.Now())
+	newIndex, er := s.openOneIndex(newPath, bc.MappingName)
+	if er != nil {
+		s.logPrintln("[pydio.grpc.log] Cannot create recovery bleve index", er.Error())
+		return
+	}
+	s.segLock.Lock()
+	s.indexes = append(s.indexes, newIndex)
+	s.cursor = len(s.indexes) - 1
+	alias := s.searchIndex
+	s.segLock.Unlock()
+	s.setSegmentDiskUsage(newPath, 0)
+	alias.Add(newIndex)
+	atomic.StoreInt64(&s.writeSegmentDocCount, 0)
+	s.writeSegmentOpenedAt = time.Now()
+	atomic.AddUint64(&s.rotationCount, 1)
+}
+
+// Resync creates a copy of current index. It has been originally used for switching analyze format from bleve to scorch.
+func (s *Indexer) Resync(ctx context.Context, logger func(string)) error {
+
+	if s.indexPath == "" {
+		// No on-disk segments to defragment (e.g. an index opened via NewIndexerFromIndex).
+		s.notifyProgress(logger, ProgressPhaseResync, 0, 0, "Indexer has no on-disk path, Resync is a no-op")
+		return nil
+	}
+
+	tempBase := filepath.Dir(s.indexPath)
+	if td := s.MustBleveConfig(ctx).ResyncTempDir; td != "" {
+		tempBase = td
+	}
+	copyDir := filepath.Join(tempBase, uuid.New())
+	e := os.MkdirAll(copyDir, 0777)
+	if e != nil {
+		return e
+	}
+	defer func() {
+		os.RemoveAll(copyDir)
+	}()
+	copyPath := filepath.Join(copyDir, filepath.Base(s.indexPath))
+
+	dup := &Indexer{
+		DAO: s.DAO,
+	}
+	dup.SetCodex(s.codec)
+	// Use bigger batches and disable the periodic timer flush: pages are flushed explicitly
+	// below, which avoids the dup indexer's own flush timer competing for IO with the
+	// ongoing reindexing read.
+	dup.SetBulkFlush(int(s.MustBleveConfig(ctx).BatchSize)*5, true)
+	if s.MustBleveConfig(ctx).BulkMode {
+		dup.SetBulkMode(true)
+	}
+	if UnitTestEnv {
+		dup.inserts = make(chan interface{})
+	} else {
+		dup.inserts = make(chan interface{}, BufferedChanSize)
+	}
+	er := dup.Open(ctx, copyPath)
+	if er != nil {
+		return er
+	}
+	s.notifyProgress(logger, ProgressPhaseResync, 0, 0, "Listing Index inside new one")
+
+	q := bleve.NewMatchAllQuery()
+	req := bleve.NewSearchRequest(q)
+	req.Size = 5000
+	page := 0
+	var reindexed int
+
+	for {
+		if err := ctx.Err(); err != nil {
+			dup.Close(ctx)
+			return err
+		}
+
+		req.From = page * req.Size
+		req.Fields = []string{"*"}
+		sr, err := s.searchAlias(ctx, req)
+		if err != nil {
+			s.logPrintln(err)
+			return err
+		}
+		s.notifyProgress(logger, ProgressPhaseResync, reindexed, int(sr.Total), fmt.Sprintf("Reindexing logs from page %d\n", page))
+		for _, hit := range sr.Hits {
+			um, e := s.codec.Unmarshal(hit)
+			if e != nil {
+				s.logPrintln(e)
+				continue
+			}
+			mu, e := s.codec.Marshal(um)
+			if e != nil {
+				s.logPrintln(e)
+				continue
+			}
+			dup.inserts <- insertEnvelope{ctx: ctx, data: mu}
+			reindexed++
+		}
+		// Flush explicitly between pages instead of relying on the (now disabled) timer. Flush
+		// only signals dup's watchInserts and does not wait for the commit, so the next page's
+		// read can overlap with it.
+		if er := dup.Flush(ctx); er != nil {
+			return er
+		}
+		s.notifyProgress(nil, ProgressPhaseResync, reindexed, int(sr.Total), fmt.Sprintf("Reindexed %d/%d", reindexed, sr.Total))
+		if err := ctx.Err(); err != nil {
+			// Cancelled mid-copy: the original index hasn't been touched yet, just tear
+			// down the partial copy (copyDir is also removed by the deferred cleanup).
+			dup.Close(ctx)
+			return err
+		}
+		if sr.Total <= uint64((page+1)*req.Size) {
+			break
+		}
+		page++
+
+	}
+	if er := dup.Flush(ctx); er != nil {
+		return er
+	}
+	if err := ctx.Err(); err != nil {
+		// Last chance to bail before the irreversible switch below: closing/removing the
+		// original and moving the copy into place. The original is still untouched here.
+		dup.Close(ctx)
+		return err
+	}
+	if er := s.Close(ctx); er != nil {
+		return er
+	}
+	if er := dup.Close(ctx); er != nil {
+		return er
+	}
+	// Both Close calls above block until their respective watchInserts has actually closed
+	// every index handle, so there is no need to additionally sleep here.
+
+	s.notifyProgress(logger, ProgressPhaseResync, reindexed, reindexed, "Removing old indexes")
+	for _, ip := range s.listIndexes() {
+		if err := os.RemoveAll(filepath.Join(filepath.Dir(s.indexPath), ip)); err != nil {
+			return err
+		}
+	}
+	s.notifyProgress(logger, ProgressPhaseResync, reindexed, reindexed, "Moving new indexes")
+	for _, ip := range dup.listIndexes() {
+		src := filepath.Join(copyDir, ip)
+		target := filepath.Join(filepath.Join(filepath.Dir(s.indexPath), ip))
+		if err := moveDir(src, target); err != nil {
+			return err
+		}
+	}
+	s.notifyProgress(logger, ProgressPhaseResync, reindexed, reindexed, "Restarting new mr")
+	if err := s.Open(ctx, s.indexPath); err != nil {
+		return err
+	}
+	s.notifyProgress(logger, ProgressPhaseResync, reindexed, reindexed, "Resync operation done")
+
+	s.updateStatus()
+
+	return nil
+
+}
+
+// Merge consolidates every rotated (non-write) segment into a single new one, reducing the
+// alias fan-out that builds up over time when RotationSize is small. Unlike Resync, it runs
+// online: the merged segment is built from a read-only alias over the existing rotated segments
+// while ingestion keeps going against the untouched write segment, and is only swapped into
+// s.indexes/searchIndex - under flushLock, which briefly pauses writers for the swap itself, not
+// for the bulk of the copy - once it has been closed and reopened from disk to confirm it is
+// sound. The source segment directories are removed only after that swap succeeds.
+func (s *Indexer) Merge(ctx context.Context, logger func(string)) error {
+	if s.indexPath == "" {
+		logger("Indexer has no on-disk path, Merge is a no-op")
+		return nil
+	}
+
+	s.flushLock.Lock()
+	snap := s.snapshotSegments()
+	s.flushLock.Unlock()
+	writeIdx := snap.cursor
+	var candidates []bleve.Index
+	for i, idx := range snap.indexes {
+		if i != writeIdx {
+			candidates = append(candidates, idx)
+		}
+	}
+
+	if len(candidates) < 2 {
+		logger("Fewer than two rotated segments, nothing to merge")
+		return nil
+	}
+
+	bc := s.MustBleveConfig(ctx)
+	tempPath := filepath.Join(filepath.Dir(s.indexPath), fmt.Sprintf("%s-merge-%s", filepath.Base(s.indexPath), uuid.New()))
+	merged, er := s.openOneIndex(tempPath, bc.MappingName)
+	if er != nil {
+		return er
+	}
+	cleanup := func() {
+		merged.Close()
+		os.RemoveAll(tempPath)
+	}
+
+	alias := bleve.NewIndexAlias(candidates...)
+	q := bleve.NewMatchAllQuery()
+	req := bleve.NewSearchRequest(q)
+	req.Size = 5000
+	page := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			cleanup()
+			return err
+		}
+		logger(fmt.Sprintf("Merging rotated segments, page %d", page))
+		req.From = page * req.Size
+		req.Fields = []string{"*"}
+		sr, err := alias.SearchInContext(ctx, req)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		batch := merged.NewBatch()
+		for _, hit := range sr.Hits {
+			um, e := s.codec.Unmarshal(hit)
+			if e != nil {
+				continue
+			}
+			mu, e := s.codec.Marshal(um)
+			if e != nil {
+				continue
+			}
+			batch.Index(hit.ID, mu)
+		}
+		if err := merged.Batch(batch); err != nil {
+			cleanup()
+			return err
+		}
+		if sr.Total <= uint64((page+1)*req.Size) {
+			break
+		}
+		page++
+	}
+	if err := merged.Close(); err != nil {
+		os.RemoveAll(tempPath)
+		return err
+	}
+	// Reopen from disk to confirm the merged segment is sound before touching the live alias.
+	reopened, er := s.openOneIndex(tempPath, bc.MappingName)
+	if er != nil {
+		os.RemoveAll(tempPath)
+		return fmt.Errorf("merged segment failed verification: %w", er)
+	}
+
+	s.flushLock.Lock()
+	s.segLock.Lock()
+	// Re-resolve the write segment from live state, not from writeIdx (captured before the
+	// unlocked scan/merge above): a concurrent rotation could have moved s.cursor on since then,
+	// and using the stale identity here would point s.cursor back at an already-rotated segment.
+	var writeIndex bleve.Index
+	if s.cursor >= 0 && s.cursor < len(s.indexes) {
+		writeIndex = s.indexes[s.cursor]
+	}
+	kept := make([]bleve.Index, 0, len(s.indexes)-len(candidates)+1)
+	for _, idx := range s.indexes {
+		isCandidate := false
+		for _, c := range candidates {
+			if c == idx {
+				isCandidate = true
+				s.searchIndex.Remove(idx)
+				break
+			}
+		}
+		if !isCandidate {
+			kept = append(kept, idx)
+		}
+	}
+	kept = append(kept, reopened)
+	s.searchIndex.Add(reopened)
+	s.indexes = kept
+	s.cursor = -1
+	for i, idx := range s.indexes {
+		if idx == writeIndex {
+			s.cursor = i
+		}
+	}
+	s.segLock.Unlock()
+	s.flushLock.Unlock()
+
+	logger("Removing merged source segments")
+	for _, idx := range candidates {
+		name := idx.Name()
+		idx.Close()
+		if name != "" {
+			os.RemoveAll(name)
+			s.removeManifestSegment(name)
+		}
+	}
+	s.recordManifestSegment(reopened.Name(), "merge")
+	s.refreshTotalDiskUsage()
+	logger("Merge operation done")
+	return nil
+}
+
+// computeTruncateRemovals returns the basenames of the segments Truncate(max) would remove, in
+// the order Truncate removes them, without touching the filesystem. max == 0 means every
+// segment, oldest-first; otherwise it walks segments newest-to-oldest accumulating disk usage and marks
+// the rest for removal once the running total exceeds max, listing them newest-first in that
+// same walk - exactly the decision Truncate itself acts on, so TruncatePreview and Truncate can
+// never drift apart.
+func (s *Indexer) computeTruncateRemovals(max int64, dir string) []string {
+	indexes := s.listIndexes()
+	if max == 0 {
+		return indexes
+	}
+	var removed []string
+	var total int64
+	var remove bool
+	for i := len(indexes) - 1; i >= 0; i-- {
+		if remove {
+			removed = append(removed, indexes[i])
+		} else if u, e := indexDiskUsage(filepath.Join(dir, indexes[i])); e == nil {
+			total += u
+			remove = total > max
+		}
+	}
+	return removed
+}
+
+// TruncatePreview reports which segments Truncate(ctx, max, ...) would remove, without closing
+// the indexer or touching the filesystem, so operators can check the effect before committing to
+// it. It returns full paths, in the same order Truncate deletes them (see
+// computeTruncateRemovals).
+func (s *Indexer) TruncatePreview(ctx context.Context, max int64) ([]string, error) {
+	if s.indexPath == "" {
+		return nil, nil
+	}
+	dir := filepath.Dir(s.indexPath)
+	var paths []string
+	for _, name := range s.computeTruncateRemovals(max, dir) {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return paths, nil
+}
+
+// Truncate gathers size of existing indexes, starting from last. When max is reached
+// it starts deleting all previous indexes.
+func (s *Indexer) Truncate(ctx context.Context, max int64, logger func(string)) error {
+	if s.indexPath == "" {
+		// No on-disk segments to free space from (e.g. an index opened via NewIndexerFromIndex).
+		s.notifyProgress(logger, ProgressPhaseTruncate, 0, 0, "Indexer has no on-disk path, Truncate is a no-op")
+		return nil
+	}
+	s.notifyProgress(logger, ProgressPhaseTruncate, 0, 0, "Closing log server")
+	dir := filepath.Dir(s.indexPath)
+	if er := s.Close(ctx); er != nil {
+		return er
+	}
+	// Close blocks until watchInserts has actually closed every index handle, so the index
+	// files below are safe to remove/move without an extra fixed-delay sleep.
+
+	removals := s.computeTruncateRemovals(max, dir)
+	if max == 0 {
+		s.notifyProgress(logger, ProgressPhaseTruncate, 0, len(removals), "Truncate index to 0: remove and recreate")
+	} else {
+		s.notifyProgress(logger, ProgressPhaseTruncate, 0, len(removals), "Start purging old files")
+	}
+	for i, idxName := range removals {
+		s.notifyProgress(logger, ProgressPhaseTruncate, i, len(removals), " - Remove "+filepath.Join(dir, idxName))
+		if er := os.RemoveAll(filepath.Join(dir, idxName)); er != nil {
+			if max == 0 {
+				return er
+			}
+			s.notifyProgress(logger, ProgressPhaseTruncate, i, len(removals), fmt.Sprintf("cannot remove index %s", idxName))
+			continue
+		}
+		s.removeManifestSegment(idxName)
+	}
+	if max == 0 {
+		s.notifyProgress(logger, ProgressPhaseTruncate, len(removals), len(removals), "Re-opening indexer")
+		if er := s.Open(ctx, s.indexPath); er != nil {
+			return er
+		}
+		s.notifyProgress(logger, ProgressPhaseTruncate, len(removals), len(removals), "Server opened")
+		return nil
+	}
+
+	// Now restart - it will renumber files
+	s.notifyProgress(logger, ProgressPhaseTruncate, len(removals), len(removals), "Re-opening log server")
+	if er := s.Open(ctx, s.indexPath); er != nil {
+		return er
+	}
+	s.notifyProgress(logger, ProgressPhaseTruncate, len(removals), len(removals), "Truncate operation done")
+
+	s.updateStatus()
+
+	return nil
+}
+
+// AnalyzerOverrideModelProvider can optionally be implemented by a dao.IndexCodex to register
+// extra named document mappings, alongside the one returned by GetModel, so that documents of
+// the same kind can be analyzed differently depending on their content (e.g. one document
+// mapping per language, each with its own per-field analyzer). The type name is the key a
+// document is routed to: have the values returned by Marshal implement bleve's own
+// mapping.Classifier interface (Type() string, or a "_type" field for plain maps) to select
+// which of these mappings - or the default one - indexes a given document. This is bleve's
+// existing per-document type mechanism; this hook only gives the codec a way to register the
+// extra mappings it relies on.
+type AnalyzerOverrideModelProvider interface {
+	GetAnalyzerOverrideModels(sc configx.Values) map[string]*mapping.DocumentMapping
+}
+
+// AnalysisProvider can optionally be implemented by a dao.IndexCodex to contribute its own
+// analyzer, tokenizer, char filter and token filter registrations to the mapping openOneIndex (and
+// AddShadowIndex) build, the same way AnalyzerOverrideModelProvider contributes extra document
+// mappings. Unlike registerCustomAnalysis's "textAnalysis" config block, these definitions come
+// from the codec itself - useful when a codec knows it needs a specific tokenizer (e.g. a
+// path-hierarchy tokenizer for path-like fields, or keyword+lowercase for IDs) regardless of what
+// a given deployment's config does or doesn't set. It runs after registerCustomAnalysis, so a
+// codec-provided definition can reuse names already registered from config, or register its own
+// from scratch.
+type AnalysisProvider interface {
+	RegisterAnalysis(indexMapping *mapping.IndexMappingImpl) error
+}
+
+// registerCodecAnalysis calls codec's RegisterAnalysis if it implements AnalysisProvider, and is a
+// no-op otherwise.
+func registerCodecAnalysis(indexMapping *mapping.IndexMappingImpl, codec dao.IndexCodex) error {
+	ap, ok := codec.(AnalysisProvider)
+	if !ok {
+		return nil
+	}
+	return ap.RegisterAnalysis(indexMapping)
+}
+
+// registerCustomAnalysis reads optional char filter, tokenizer, token filter and analyzer
+// definitions from the service config and registers them on the mapping before it is used to
+// create an index. Config is expected under the "textAnalysis" key, e.g.:
+//
+//	textAnalysis:
+//	  charFilters:
+//	    <name>: {<bleve char filter config>}
+//	  tokenizers:
+//	    <name>: {<bleve tokenizer config>}
+//	  tokenFilters:
+//	    <name>: {<bleve token filter config>}
+//	  analyzers:
+//	    <name>: {<bleve analyzer config>}
+//
+// Definitions are registered in dependency order (char filters, then tokenizers, then token
+// filters, then analyzers) so that an analyzer may reference a custom tokenizer or filter defined
+// alongside it. Any registration failure is returned so that opening the index fails clearly
+// rather than silently falling back to the default analyzer.
+func registerCustomAnalysis(indexMapping *mapping.IndexMappingImpl, sc configx.Values) error {
+	if sc == nil {
+		return nil
+	}
+	ta := sc.Val("textAnalysis")
+	if ta == nil {
+		return nil
+	}
+	for name, cfg := range ta.Val("charFilters").Map() {
+		def, ok := cfg.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid char filter definition for %q", name)
+		}
+		if err := indexMapping.AddCustomCharFilter(name, def); err != nil {
+			return fmt.Errorf("cannot register char filter %q: %v", name, err)
+		}
+	}
+	for name, cfg := range ta.Val("tokenizers").Map() {
+		def, ok := cfg.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid tokenizer definition for %q", name)
+		}
+		if err := indexMapping.AddCustomTokenizer(name, def); err != nil {
+			return fmt.Errorf("cannot register tokenizer %q: %v", name, err)
+		}
+	}
+	for name, cfg := range ta.Val("tokenFilters").Map() {
+		def, ok := cfg.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid token filter definition for %q", name)
+		}
+		if err := indexMapping.AddCustomTokenFilter(name, def); err != nil {
+			return fmt.Errorf("cannot register token filter %q: %v", name, err)
+		}
+	}
+	for name, cfg := range ta.Val("analyzers").Map() {
+		def, ok := cfg.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid analyzer definition for %q", name)
+		}
+		if err := indexMapping.AddCustomAnalyzer(name, def); err != nil {
+			return fmt.Errorf("cannot register analyzer %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// AddShadowIndex opens a secondary, independently-queryable index alongside the main one, built
+// from mappingModel instead of the codec's own GetModel, so an alternate analyzer/mapping can be
+// evaluated against live data before committing to it. From then on it receives a copy of every
+// subsequent insert (see teeToShadows), but FindMany never reads it - query it explicitly with
+// SearchShadow. Call RemoveShadowIndex once the comparison is done to clean it up.
+func (s *Indexer) AddShadowIndex(ctx context.Context, name string, mappingModel *mapping.DocumentMapping) error {
+	if s.indexPath == "" {
+		return fmt.Errorf("cannot add a shadow index to an in-memory indexer")
+	}
+	s.shadowLock.Lock()
+	defer s.shadowLock.Unlock()
+	if _, exists := s.shadowIndexes[name]; exists {
+		return fmt.Errorf("a shadow index named %q already exists", name)
+	}
+	indexMapping := bleve.NewIndexMapping()
+	if er := registerCustomAnalysis(indexMapping, s.serviceConfigs); er != nil {
+		return er
+	}
+	if er := registerCodecAnalysis(indexMapping, s.codec); er != nil {
+		return er
+	}
+	if mappingModel != nil {
+		indexMapping.AddDocumentMapping(s.MustBleveConfig(ctx).MappingName, mappingModel)
+	}
+	idx, err := bleve.NewUsing(s.shadowIndexPath(name), indexMapping, scorch.Name, boltdb.Name, nil)
+	if err != nil {
+		return err
+	}
+	if s.shadowIndexes == nil {
+		s.shadowIndexes = map[string]bleve.Index{}
+	}
+	s.shadowIndexes[name] = idx
+	return nil
+}
+
+// SearchShadow runs query (built with the regular codec, or customCodec if set) against a shadow
+// index added with AddShadowIndex, for comparing its analysis/ranking against the main index.
+func (s *Indexer) SearchShadow(ctx context.Context, name string, query interface{}, offset, limit int32, sortFields string, sortDesc bool, customCodec dao.IndexCodex) (*bleve.SearchResult, error) {
+	s.shadowLock.Lock()
+	idx, ok := s.shadowIndexes[name]
+	s.shadowLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no shadow index named %q", name)
+	}
+	codec := s.codec
+	if customCodec != nil {
+		codec = customCodec
+	}
+	request, _, err := codec.BuildQuery(query, offset, limit, sortFields, sortDesc)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := request.(*bleve.SearchRequest)
+	if !ok {
+		return nil, fmt.Errorf("Unrecognized searchRequest type")
+	}
+	return idx.SearchInContext(ctx, req)
+}
+
+// RemoveShadowIndex closes and removes the on-disk files of a shadow index added with
+// AddShadowIndex. It is a no-op if no shadow index exists under that name.
+func (s *Indexer) RemoveShadowIndex(name string) error {
+	s.shadowLock.Lock()
+	idx, ok := s.shadowIndexes[name]
+	delete(s.shadowIndexes, name)
+	s.shadowLock.Unlock()
+	if !ok {
+		return nil
 	}
-	du, e := indexDiskUsage(checkPath)
-	if e != nil {
-		fmt.Println("[pydio.grpc.log] Cannot compute disk usage for bleve index", e.Error())
-		return
+	if err := idx.Close(); err != nil {
+		return err
 	}
-	if du > bc.RotationSize {
-		fmt.Println("Rotating "+s.indexPath+" for size ", du)
-		// Open a new index
-		newPath := fmt.Sprintf("%s.%04d", s.indexPath, len(s.indexes))
-		newIndex, er := s.openOneIndex(newPath, bc.MappingName)
-		if er != nil {
-			fmt.Println("[pydio.grpc.log] Cannot create new bleve index", er.Error())
-			return
+	return os.RemoveAll(s.shadowIndexPath(name))
+}
+
+// teeToShadows indexes an already-marshalled document into every shadow index added via
+// AddShadowIndex, best-effort: a shadow failing to index a document does not affect the main
+// write path, it is only logged.
+func (s *Indexer) teeToShadows(id string, msg interface{}) {
+	s.shadowLock.Lock()
+	defer s.shadowLock.Unlock()
+	for name, idx := range s.shadowIndexes {
+		if err := idx.Index(id, msg); err != nil {
+			s.logPrintln("[pydio.grpc.log] Error indexing into shadow index", name, err.Error())
 		}
-		s.indexes = append(s.indexes, newIndex)
-		s.searchIndex.Add(newIndex)
-		s.cursor = len(s.indexes) - 1
 	}
+}
 
-	s.updateStatus()
+func (s *Indexer) shadowIndexPath(name string) string {
+	return fmt.Sprintf("%s-shadow-%s", s.indexPath, name)
 }
 
-func (s *Indexer) flush() {
-	if s.crtBatch != nil {
-		s.getWriteIndex().Batch(s.crtBatch)
-		s.rotateIfNeeded()
-		s.crtBatch = nil
+// RestoreSegment replaces one named segment's on-disk directory with the contents of a backup
+// stream, while the rest of the index stays live and searchable. name is the segment's base
+// directory name as reported by listIndexes (e.g. "docs" or "docs.0002"); r supplies a tar
+// archive of that segment's directory tree, as a full backup routine would capture it. It is the
+// surgical counterpart to a full Backup/Restore: when only one segment is flagged corrupt, this
+// avoids rebuilding the whole index from scratch.
+//
+// The swap happens under flushLock, so it is serialized with the write path, and the corrupt
+// segment's directory is kept alongside the restored one (suffixed "-corrupt") until the new
+// segment has been reopened and re-added to the alias, so a failure midway leaves the old data
+// recoverable instead of lost.
+func (s *Indexer) RestoreSegment(name string, r io.Reader) error {
+	if s.indexPath == "" {
+		return fmt.Errorf("bleve: indexer has no on-disk path, cannot restore a segment")
 	}
-}
+	segPath := filepath.Join(filepath.Dir(s.indexPath), name)
 
-// Resync creates a copy of current index. It has been originally used for switching analyze format from bleve to scorch.
-func (s *Indexer) Resync(ctx context.Context, logger func(string)) error {
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
 
-	copyDir := filepath.Join(filepath.Dir(s.indexPath), uuid.New())
-	e := os.Mkdir(copyDir, 0777)
-	if e != nil {
-		return e
+	segIdx := -1
+	s.segLock.RLock()
+	for i, idx := range s.indexes {
+		if idx.Name() == segPath {
+			segIdx = i
+			break
+		}
+	}
+	s.segLock.RUnlock()
+	if segIdx < 0 {
+		return fmt.Errorf("bleve: no such segment %q", name)
 	}
-	defer func() {
-		os.RemoveAll(copyDir)
-	}()
-	copyPath := filepath.Join(copyDir, filepath.Base(s.indexPath))
 
-	dup := &Indexer{
-		DAO: s.DAO,
+	restorePath := segPath + "-restore"
+	if err := os.RemoveAll(restorePath); err != nil {
+		return err
 	}
-	dup.SetCodex(s.codec)
-	if UnitTestEnv {
-		dup.inserts = make(chan interface{})
-	} else {
-		dup.inserts = make(chan interface{}, BufferedChanSize)
+	if err := untarDir(restorePath, r); err != nil {
+		os.RemoveAll(restorePath)
+		return err
 	}
-	er := dup.Open(ctx, copyPath)
-	if er != nil {
-		return er
+	if !isBleveIndexDir(restorePath) {
+		os.RemoveAll(restorePath)
+		return fmt.Errorf("bleve: backup stream for segment %q is not a valid bleve index", name)
 	}
-	logger("Listing Index inside new one")
 
-	q := bleve.NewMatchAllQuery()
-	req := bleve.NewSearchRequest(q)
-	req.Size = 5000
-	page := 0
+	s.segLock.Lock()
+	oldIdx := s.indexes[segIdx]
+	s.searchIndex.Remove(oldIdx)
+	s.segLock.Unlock()
+	if err := oldIdx.Close(); err != nil {
+		s.logPrintln("[pydio.grpc.log] Error closing segment being restored", err.Error())
+	}
 
-	for {
+	corruptPath := segPath + "-corrupt"
+	os.RemoveAll(corruptPath)
+	if err := os.Rename(segPath, corruptPath); err != nil {
+		s.logPrintln("[pydio.grpc.log] Could not preserve corrupt segment copy", err.Error())
+	}
+	if err := moveDir(restorePath, segPath); err != nil {
+		return err
+	}
 
-		logger(fmt.Sprintf("Reindexing logs from page %d\n", page))
-		req.From = page * req.Size
-		req.Fields = []string{"*"}
-		sr, err := s.searchIndex.SearchInContext(ctx, req)
-		if err != nil {
-			fmt.Println(err)
+	newIdx, err := s.openOneIndex(segPath, s.MustBleveConfig(context.Background()).MappingName)
+	if err != nil {
+		return err
+	}
+	s.segLock.Lock()
+	s.indexes[segIdx] = newIdx
+	s.searchIndex.Add(newIdx)
+	s.segLock.Unlock()
+	s.refreshSegmentDiskUsage(segPath)
+	os.RemoveAll(corruptPath)
+
+	return nil
+}
+
+// untarDir extracts a tar archive read from r into target, creating target and any intermediate
+// directories as needed.
+func untarDir(target string, r io.Reader) error {
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
 			return err
 		}
-		for _, hit := range sr.Hits {
-			um, e := s.codec.Unmarshal(hit)
-			if e != nil {
-				fmt.Println(e)
-				continue
+		dstPath := filepath.Join(target, hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dstPath, os.FileMode(hdr.Mode)); err != nil {
+				return err
 			}
-			mu, e := s.codec.Marshal(um)
-			if e != nil {
-				fmt.Println(e)
-				continue
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
 			}
-			dup.inserts <- mu
-		}
-		if sr.Total <= uint64((page+1)*req.Size) {
-			break
 		}
-		page++
-
-	}
-	if er := dup.Flush(ctx); er != nil {
-		return er
-	}
-	if er := s.Close(ctx); er != nil {
-		return er
-	}
-	if er := dup.Close(ctx); er != nil {
-		return er
 	}
-	<-time.After(5 * time.Second) // Make sure original is closed
+}
 
-	logger("Removing old indexes")
-	for _, ip := range s.listIndexes() {
-		if err := os.RemoveAll(filepath.Join(filepath.Dir(s.indexPath), ip)); err != nil {
-			return err
-		}
+// PauseRotation suspends rotateIfNeeded from creating new segments, leaving any rotation or
+// flush already in flight to finish normally. ResumeRotation lifts the suspension. Backup uses
+// this to pin the segment list for the duration of a snapshot; it can also be called directly by
+// anyone who needs the active segment to stay put for a while (e.g. around an external tool that
+// expects a stable path).
+func (s *Indexer) PauseRotation() {
+	atomic.StoreInt32(&s.rotationPaused, 1)
+}
+
+// ResumeRotation lifts a suspension started by PauseRotation.
+func (s *Indexer) ResumeRotation() {
+	atomic.StoreInt32(&s.rotationPaused, 0)
+}
+
+// Backup writes a consistent, point-in-time tar snapshot of every segment currently in the index
+// to w, with each segment packed under its own name (so path collisions between segments are
+// impossible) - unlike RestoreSegment's single-segment tar format, which has no such prefix since
+// it only ever concerns one segment. Backup pauses rotation (see PauseRotation) and holds
+// flushLock for its entire duration, so no segment is created, renamed or flushed mid-copy.
+// InsertOne/DeleteOne keep accepting calls during the backup - they simply buffer on the
+// inserts channel, up to BufferedChanSize, until flushLock is released.
+func (s *Indexer) Backup(ctx context.Context, w io.Writer) error {
+	if s.indexPath == "" {
+		return fmt.Errorf("bleve: indexer has no on-disk path, cannot back it up")
 	}
-	logger("Moving new indexes")
-	for _, ip := range dup.listIndexes() {
-		src := filepath.Join(copyDir, ip)
-		target := filepath.Join(filepath.Join(filepath.Dir(s.indexPath), ip))
-		if err := os.Rename(src, target); err != nil {
+	s.PauseRotation()
+	defer s.ResumeRotation()
+
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+	s.flush()
+
+	dirPath := filepath.Dir(s.indexPath)
+	tw := tar.NewWriter(w)
+	for _, name := range s.listIndexes() {
+		if err := tarDir(tw, dirPath, name); err != nil {
+			tw.Close()
 			return err
 		}
 	}
-	logger("Restarting new mr")
-	if err := s.Open(ctx, s.indexPath); err != nil {
-		return err
+	return tw.Close()
+}
+
+// Snapshot is Backup's directory-tree counterpart: it copies every segment's current on-disk
+// files straight into destDir (one subdirectory per segment, named as listIndexes reports it)
+// instead of packing them into a tar stream, for callers that want a ready-to-use set of bleve
+// index directories rather than an archive to unpack later. It shares Backup's consistency
+// guarantee - rotation paused and flushLock held for the duration, with a flush first - rather
+// than scorch's own lower-level snapshot primitives, so the two stay consistent with each other
+// and with RestoreSegment; the cost is that Snapshot, like Backup, blocks new flushes (not
+// inserts, which keep buffering) until the copy finishes.
+func (s *Indexer) Snapshot(ctx context.Context, destDir string) error {
+	if s.indexPath == "" {
+		return fmt.Errorf("bleve: indexer has no on-disk path, cannot snapshot it")
 	}
-	logger("Resync operation done")
+	s.PauseRotation()
+	defer s.ResumeRotation()
 
-	s.updateStatus()
+	s.flushLock.Lock()
+	defer s.flushLock.Unlock()
+	s.flush()
 
+	dirPath := filepath.Dir(s.indexPath)
+	for _, name := range s.listIndexes() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := copyDir(filepath.Join(dirPath, name), filepath.Join(destDir, name)); err != nil {
+			return err
+		}
+	}
 	return nil
-
 }
 
-// Truncate gathers size of existing indexes, starting from last. When max is reached
-// it starts deleting all previous indexes.
-func (s *Indexer) Truncate(ctx context.Context, max int64, logger func(string)) error {
-	logger("Closing log server, waiting for five seconds")
-	dir := filepath.Dir(s.indexPath)
-	if er := s.Close(ctx); er != nil {
-		return er
+// RestoreFrom stages the segment directories found under srcDir (as produced by Snapshot) to
+// replace the indexer's own segments the next time Open is called. Unlike RestoreSegment, which
+// hot-swaps one segment into a still-running indexer, RestoreFrom only touches files on disk: it
+// must be called while the indexer is closed, and has no effect until the following Open.
+func (s *Indexer) RestoreFrom(srcDir string) error {
+	if s.indexPath == "" {
+		return fmt.Errorf("bleve: indexer has no on-disk path, cannot restore into it")
 	}
-	<-time.After(5 * time.Second)
-
-	if max == 0 {
-		logger("Truncate index to 0: remove and recreate")
-		for _, idxName := range s.listIndexes() {
-			logger(" - Remove " + filepath.Join(dir, idxName))
-			if er := os.RemoveAll(filepath.Join(dir, idxName)); er != nil {
-				return er
-			}
+	if s.isOpen() {
+		return fmt.Errorf("bleve: indexer must be closed before RestoreFrom; it takes effect on the next Open")
+	}
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	dirPath := filepath.Dir(s.indexPath)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
 		}
-		logger("Re-opening indexer")
-		if er := s.Open(ctx, s.indexPath); er != nil {
-			return er
+		segSrc := filepath.Join(srcDir, entry.Name())
+		if !isBleveIndexDir(segSrc) {
+			continue
 		}
-		logger("Server opened")
-		return nil
-	}
-
-	logger("Start purging old files")
-	indexes := s.listIndexes()
-	var i int
-	var total int64
-	var remove bool
-	for i = len(indexes) - 1; i >= 0; i-- {
-		if remove {
-			e := os.RemoveAll(filepath.Join(dir, indexes[i]))
-			if e != nil {
-				logger(fmt.Sprintf("cannot remove index %s", indexes[i]))
-			}
-		} else if u, e := indexDiskUsage(filepath.Join(dir, indexes[i])); e == nil {
-			total += u
-			remove = total > max
+		segDst := filepath.Join(dirPath, entry.Name())
+		if err := os.RemoveAll(segDst); err != nil {
+			return err
+		}
+		if err := copyDir(segSrc, segDst); err != nil {
+			return err
 		}
 	}
-	// Now restart - it will renumber files
-	logger("Re-opening log server")
-	if er := s.Open(ctx, s.indexPath); er != nil {
-		return er
-	}
-	logger("Truncate operation done")
-
-	s.updateStatus()
-
 	return nil
 }
 
+// tarDir adds the directory root/name, and everything beneath it, to tw with every entry's name
+// prefixed by "name/" so several segments can share one archive without their paths colliding.
+func tarDir(tw *tar.Writer, root, name string) error {
+	base := filepath.Join(root, name)
+	return filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		hdrName := name
+		if rel != "." {
+			hdrName = filepath.Join(name, rel)
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = hdrName
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
 // openOneIndex tries to open an existing index at a given path, or creates a new one
 func (s *Indexer) openOneIndex(bleveIndexPath string, mappingName string) (bleve.Index, error) {
 
-	index, err := bleve.Open(bleveIndexPath)
+	readOnly := s.MustBleveConfig(context.Background()).ReadOnly
+	var index bleve.Index
+	var err error
+	if readOnly {
+		// bleve.OpenUsing lets us pass "read_only" down to scorch, which opens the underlying
+		// boltdb file with bolt.Options{ReadOnly: true} - a genuine OS-level guard against this
+		// replica ever writing to a primary's files, not just an application-level no-op.
+		index, err = bleve.OpenUsing(bleveIndexPath, map[string]interface{}{"read_only": true})
+		if err != nil {
+			return nil, err
+		}
+		return index, nil
+	}
+	index, err = bleve.Open(bleveIndexPath)
 	if err != nil {
 		indexMapping := bleve.NewIndexMapping()
+		if er := registerCustomAnalysis(indexMapping, s.serviceConfigs); er != nil {
+			return nil, er
+		}
+		if er := registerCodecAnalysis(indexMapping, s.codec); er != nil {
+			return nil, er
+		}
 		if model, ok := s.codec.GetModel(s.serviceConfigs); ok {
 			if docMapping, ok := model.(*mapping.DocumentMapping); ok {
 				indexMapping.AddDocumentMapping(mappingName, docMapping)
 			}
 		}
+		if overrider, ok := s.codec.(AnalyzerOverrideModelProvider); ok {
+			for typeName, docMapping := range overrider.GetAnalyzerOverrideModels(s.serviceConfigs) {
+				indexMapping.AddDocumentMapping(typeName, docMapping)
+			}
+		}
 		// Creates the new index and initializes the server
 		if bleveIndexPath == "" {
 			index, err = bleve.NewMemOnly(indexMapping)
 		} else {
-			index, err = bleve.NewUsing(bleveIndexPath, indexMapping, scorch.Name, boltdb.Name, nil)
+			bc := s.MustBleveConfig(context.Background())
+			var kvConfig map[string]interface{}
+			if len(s.storeConfig) > 0 {
+				kvConfig = make(map[string]interface{}, len(s.storeConfig)+1)
+				for k, v := range s.storeConfig {
+					kvConfig[k] = v
+				}
+			}
+			if s.bulkMode {
+				if kvConfig == nil {
+					kvConfig = make(map[string]interface{}, 1)
+				}
+				kvConfig["unsafe_batch"] = true
+			}
+			indexType, kvStore := bc.IndexType, bc.KVStoreName
+			if indexType == "" {
+				indexType = IndexTypeScorch
+			}
+			if kvStore == "" {
+				kvStore = KVStoreBoltDB
+			}
+			index, err = bleve.NewUsing(bleveIndexPath, indexMapping, indexType, kvStore, kvConfig)
 		}
 		if err != nil {
 			return nil, err
@@ -726,6 +4786,128 @@ func (s *Indexer) openOneIndex(bleveIndexPath string, mappingName string) (bleve
 
 }
 
+// IndexSegmentStats describes one segment (the active write index or a rotated one) as reported
+// by Stats.
+type IndexSegmentStats struct {
+	Path      string
+	DocCount  uint64
+	DiskUsage int64
+}
+
+// IndexStats is a point-in-time snapshot of the Indexer's segments, returned by Stats.
+type IndexStats struct {
+	NumIndexes int
+	Cursor     int
+	TotalSize  int64
+	Indexes    []IndexSegmentStats
+}
+
+// SegmentStats returns a snapshot of the Indexer's rotated segments: per-segment document counts
+// and disk usage (reusing indexDiskUsage), the active cursor and the total size. It complements
+// the coarser dao.DAO.Stats() map with a typed, per-segment breakdown for monitoring dashboards
+// that would otherwise shell out to `du` on the index directory. It works on a copy of the
+// segment list taken under segLock (see snapshotSegments), the lock every update of s.indexes
+// goes through, so it never observes a torn append and is safe to call concurrently with inserts
+// and flushes.
+func (s *Indexer) SegmentStats() (*IndexStats, error) {
+	snap := s.snapshotSegments()
+	indexes := snap.indexes
+	cursor := snap.cursor
+
+	out := &IndexStats{
+		NumIndexes: len(indexes),
+		Cursor:     cursor,
+		Indexes:    make([]IndexSegmentStats, 0, len(indexes)),
+	}
+	for _, idx := range indexes {
+		segPath := idx.Name()
+		seg := IndexSegmentStats{Path: segPath}
+		if count, e := idx.DocCount(); e == nil {
+			seg.DocCount = count
+		}
+		if du, e := indexDiskUsage(segPath); e == nil {
+			seg.DiskUsage = du
+		}
+		out.TotalSize += seg.DiskUsage
+		out.Indexes = append(out.Indexes, seg)
+	}
+	return out, nil
+}
+
+// TotalDiskUsage returns the last known total on-disk size across all segments. It is maintained
+// incrementally (see setSegmentDiskUsage/refreshSegmentDiskUsage) rather than recomputed on every
+// call, so admin dashboards can poll it frequently without triggering a recursive directory walk.
+func (s *Indexer) TotalDiskUsage() int64 {
+	return atomic.LoadInt64(&s.totalDiskUsage)
+}
+
+// setSegmentDiskUsage records an already-known size for a segment directory (e.g. one just
+// computed by rotateIfNeeded, or zero for a freshly created empty segment), applying the delta
+// to totalDiskUsage without touching the filesystem again.
+func (s *Indexer) setSegmentDiskUsage(segPath string, size int64) {
+	if segPath == "" {
+		return
+	}
+	s.diskUsageCacheLock.Lock()
+	if s.diskUsageCache == nil {
+		s.diskUsageCache = map[string]int64{}
+	}
+	prev := s.diskUsageCache[segPath]
+	s.diskUsageCache[segPath] = size
+	s.diskUsageCacheLock.Unlock()
+	atomic.AddInt64(&s.totalDiskUsage, size-prev)
+}
+
+// refreshSegmentDiskUsage re-walks a single segment directory and applies the delta to
+// totalDiskUsage. Used after events that touch exactly one segment (e.g. RestoreSegment bringing
+// back a single segment) where a full scan would be wasteful.
+func (s *Indexer) refreshSegmentDiskUsage(segPath string) {
+	if segPath == "" {
+		return
+	}
+	du, err := indexDiskUsage(segPath)
+	if err != nil {
+		return
+	}
+	s.setSegmentDiskUsage(segPath, du)
+}
+
+// refreshTotalDiskUsage does a full scan of every known segment and replaces the cache wholesale.
+// It runs once when Open completes and, if DiskUsageScanInterval is configured, periodically
+// afterwards to correct any drift the incremental updates may have accumulated.
+func (s *Indexer) refreshTotalDiskUsage() {
+	if s.indexPath == "" {
+		return
+	}
+	dir := filepath.Dir(s.indexPath)
+	cache := map[string]int64{}
+	var total int64
+	for _, name := range s.listIndexes() {
+		if du, err := indexDiskUsage(filepath.Join(dir, name)); err == nil {
+			cache[filepath.Join(dir, name)] = du
+			total += du
+		}
+	}
+	s.diskUsageCacheLock.Lock()
+	s.diskUsageCache = cache
+	s.diskUsageCacheLock.Unlock()
+	atomic.StoreInt64(&s.totalDiskUsage, total)
+}
+
+// watchDiskUsage periodically corrects TotalDiskUsage via a full scan, until the indexer closes.
+func (s *Indexer) watchDiskUsage(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			s.refreshTotalDiskUsage()
+		case <-s.insertsDone:
+			return
+		}
+	}
+}
+
 // indexDiskUsage is a simple implementation for computing directory size
 func indexDiskUsage(currPath string) (int64, error) {
 	var size int64
@@ -754,3 +4936,47 @@ func indexDiskUsage(currPath string) (int64, error) {
 
 	return size, nil
 }
+
+// moveDir moves a directory from src to target, falling back to a recursive copy-then-remove
+// when they live on different devices (os.Rename fails cross-device, e.g. when ResyncTempDir
+// points at a different volume than the final index location).
+func moveDir(src, target string) error {
+	if err := os.Rename(src, target); err == nil {
+		return nil
+	} else if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyDir(src, target); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyDir recursively copies a directory tree from src to target.
+func copyDir(src, target string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(target, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, info.Mode())
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright (c) 2019-2022. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package bleve
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+// lazyIndex wraps a rotated segment that may not yet be opened on disk, deferring the actual
+// bleve.Open/bleve.New call to the first method that needs it. This is what LazyOpenRotated
+// trades on Open: instead of paying the file-handle/cache cost of every rotated segment up
+// front, only the active write segment is opened eagerly, and older ones are opened the first
+// time a search touches them through the alias. If IdleTimeout is set, an opened segment whose
+// idle time exceeds it is closed again, so a burst of historical queries doesn't leave hundreds
+// of handles open indefinitely.
+//
+// Note that bleve's IndexAlias fans a search out to every member index concurrently (see
+// bleve.MultiSearch), so a broad query against the alias will still open every rotated segment
+// it's a member of - lazyIndex saves the startup cost and the idle-memory cost, not the cost of
+// a query that genuinely needs to touch old data.
+type lazyIndex struct {
+	path        string
+	mappingName string
+	open        func(path, mappingName string) (bleve.Index, error)
+	idleTimeout time.Duration
+
+	mu         sync.Mutex
+	idx        bleve.Index
+	name       string
+	lastUsed   time.Time
+	closeTimer *time.Timer
+}
+
+func newLazyIndex(path, mappingName string, open func(string, string) (bleve.Index, error), idleTimeout time.Duration) *lazyIndex {
+	return &lazyIndex{path: path, mappingName: mappingName, open: open, idleTimeout: idleTimeout, name: path}
+}
+
+// ensure opens the underlying index if it isn't already, and marks it as just used so the idle
+// timer (if any) doesn't close it out from underneath the caller.
+func (l *lazyIndex) ensure() (bleve.Index, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastUsed = time.Now()
+	if l.idx == nil {
+		idx, err := l.open(l.path, l.mappingName)
+		if err != nil {
+			return nil, err
+		}
+		if l.name != "" {
+			idx.SetName(l.name)
+		}
+		l.idx = idx
+		l.scheduleIdleCloseLocked()
+	}
+	return l.idx, nil
+}
+
+func (l *lazyIndex) scheduleIdleCloseLocked() {
+	if l.idleTimeout <= 0 {
+		return
+	}
+	if l.closeTimer != nil {
+		l.closeTimer.Stop()
+	}
+	l.closeTimer = time.AfterFunc(l.idleTimeout, l.closeIfIdle)
+}
+
+// closeIfIdle is invoked by closeTimer. If the segment was touched again since the timer was
+// scheduled, it reschedules for the remaining time instead of closing.
+func (l *lazyIndex) closeIfIdle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.idx == nil {
+		return
+	}
+	if since := time.Since(l.lastUsed); since < l.idleTimeout {
+		l.closeTimer = time.AfterFunc(l.idleTimeout-since, l.closeIfIdle)
+		return
+	}
+	l.idx.Close()
+	l.idx = nil
+}
+
+func (l *lazyIndex) Index(id string, data interface{}) error {
+	idx, err := l.ensure()
+	if err != nil {
+		return err
+	}
+	return idx.Index(id, data)
+}
+
+func (l *lazyIndex) Delete(id string) error {
+	idx, err := l.ensure()
+	if err != nil {
+		return err
+	}
+	return idx.Delete(id)
+}
+
+func (l *lazyIndex) NewBatch() *bleve.Batch {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil
+	}
+	return idx.NewBatch()
+}
+
+func (l *lazyIndex) Batch(b *bleve.Batch) error {
+	idx, err := l.ensure()
+	if err != nil {
+		return err
+	}
+	return idx.Batch(b)
+}
+
+func (l *lazyIndex) Document(id string) (index.Document, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.Document(id)
+}
+
+func (l *lazyIndex) DocCount() (uint64, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return 0, err
+	}
+	return idx.DocCount()
+}
+
+func (l *lazyIndex) Search(req *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.Search(req)
+}
+
+func (l *lazyIndex) SearchInContext(ctx context.Context, req *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.SearchInContext(ctx, req)
+}
+
+func (l *lazyIndex) Fields() ([]string, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.Fields()
+}
+
+func (l *lazyIndex) FieldDict(field string) (index.FieldDict, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.FieldDict(field)
+}
+
+func (l *lazyIndex) FieldDictRange(field string, startTerm, endTerm []byte) (index.FieldDict, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.FieldDictRange(field, startTerm, endTerm)
+}
+
+func (l *lazyIndex) FieldDictPrefix(field string, termPrefix []byte) (index.FieldDict, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.FieldDictPrefix(field, termPrefix)
+}
+
+// Close closes the underlying index if it is currently open, without opening it just to close
+// it again.
+func (l *lazyIndex) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closeTimer != nil {
+		l.closeTimer.Stop()
+	}
+	if l.idx == nil {
+		return nil
+	}
+	err := l.idx.Close()
+	l.idx = nil
+	return err
+}
+
+func (l *lazyIndex) Mapping() mapping.IndexMapping {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil
+	}
+	return idx.Mapping()
+}
+
+func (l *lazyIndex) Stats() *bleve.IndexStat {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil
+	}
+	return idx.Stats()
+}
+
+func (l *lazyIndex) StatsMap() map[string]interface{} {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil
+	}
+	return idx.StatsMap()
+}
+
+func (l *lazyIndex) GetInternal(key []byte) ([]byte, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.GetInternal(key)
+}
+
+func (l *lazyIndex) SetInternal(key, val []byte) error {
+	idx, err := l.ensure()
+	if err != nil {
+		return err
+	}
+	return idx.SetInternal(key, val)
+}
+
+func (l *lazyIndex) DeleteInternal(key []byte) error {
+	idx, err := l.ensure()
+	if err != nil {
+		return err
+	}
+	return idx.DeleteInternal(key)
+}
+
+// Name returns the segment's logical name without forcing it open.
+func (l *lazyIndex) Name() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.name
+}
+
+// SetName overrides the logical name without forcing the segment open.
+func (l *lazyIndex) SetName(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.name = name
+	if l.idx != nil {
+		l.idx.SetName(name)
+	}
+}
+
+func (l *lazyIndex) Advanced() (index.Index, error) {
+	idx, err := l.ensure()
+	if err != nil {
+		return nil, err
+	}
+	return idx.Advanced()
+}
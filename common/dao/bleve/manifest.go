@@ -0,0 +1,231 @@
+/*
+ * Copyright (c) 2019-2022. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package bleve
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestEntry records metadata about one segment directory that its name alone can't carry:
+// when it was created, why it was rotated into existence, and its position the last time the
+// manifest was written. Order still mirrors what listIndexes derives from directory names - the
+// manifest is an additional, cheaper-to-read record of that same segment list, not (yet) a
+// replacement authority for it, so a manifest lost or corrupted between writes never strands an
+// indexer the way a lost/renamed segment directory would.
+type ManifestEntry struct {
+	Path           string    `json:"path"`
+	CreatedAt      time.Time `json:"createdAt"`
+	RotationReason string    `json:"rotationReason"`
+	Order          int       `json:"order"`
+
+	// MinTime and MaxTime are the oldest and newest document time-field values seen in this
+	// segment, recorded once by freezeSegmentTimeRange right after the segment is rotated off the
+	// write path and becomes immutable. Both are zero until then (and stay zero forever if no
+	// RetentionField/TimeField is configured), which SearchTimeWindow treats as "range unknown,
+	// always search this segment" rather than as a real all-zero range.
+	MinTime time.Time `json:"minTime,omitempty"`
+	MaxTime time.Time `json:"maxTime,omitempty"`
+
+	// RangeField is the name of the field MinTime/MaxTime were computed against (RetentionField,
+	// falling back to TimeField, at the time the segment was rotated). SearchTimeWindow only uses
+	// MinTime/MaxTime to skip a segment when it's searching this same field - a caller-supplied
+	// field that doesn't match leaves the range meaningless, so the segment must be searched
+	// rather than silently excluded.
+	RangeField string `json:"rangeField,omitempty"`
+}
+
+// manifestFilePath returns the path of the manifest file sitting alongside this indexer's
+// segments, e.g. "logs.manifest.json" next to "logs", "logs.0001", etc.
+func (s *Indexer) manifestFilePath() string {
+	dirPath, base := filepath.Split(s.indexPath)
+	return filepath.Join(dirPath, base+".manifest.json")
+}
+
+// loadManifest reads the manifest file into a map keyed by segment basename. A missing or
+// unreadable manifest is not an error: it just means every segment will be treated as having no
+// recorded metadata yet, the same as before this feature existed.
+func (s *Indexer) loadManifest() map[string]ManifestEntry {
+	result := map[string]ManifestEntry{}
+	raw, err := os.ReadFile(s.manifestFilePath())
+	if err != nil {
+		return result
+	}
+	var entries []ManifestEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return result
+	}
+	for _, e := range entries {
+		result[e.Path] = e
+	}
+	return result
+}
+
+// saveManifest writes entries to the manifest file transactionally: it serializes to a temporary
+// file in the same directory, then renames it over the real manifest path, so a crash mid-write
+// leaves the previous manifest intact rather than a half-written one.
+func (s *Indexer) saveManifest(entries map[string]ManifestEntry) error {
+	ordered := make([]ManifestEntry, 0, len(entries))
+	for _, e := range entries {
+		ordered = append(ordered, e)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Order < ordered[j].Order })
+	data, err := json.Marshal(ordered)
+	if err != nil {
+		return err
+	}
+	tmp := s.manifestFilePath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.manifestFilePath())
+}
+
+// recordManifestSegment upserts a manifest entry for a newly created segment (reason being e.g.
+// "initial", "size", "doc count" or "age" - the same vocabulary rotateIfNeeded already logs) and
+// persists the manifest. Errors are logged, never returned: the manifest is a convenience layer
+// over the directory scan listIndexes already performs, so a write failure here must not block
+// indexing.
+func (s *Indexer) recordManifestSegment(path, reason string) {
+	s.manifestLock.Lock()
+	defer s.manifestLock.Unlock()
+	entries := s.loadManifest()
+	name := filepath.Base(path)
+	entries[name] = ManifestEntry{Path: name, CreatedAt: time.Now(), RotationReason: reason, Order: len(entries)}
+	if err := s.saveManifest(entries); err != nil {
+		s.logPrintln("[pydio.grpc.log] Could not update segment manifest", err)
+	}
+}
+
+// recordManifestTimeRange stamps a segment's document time range, and the field it was computed
+// against, into its already-existing manifest entry (see freezeSegmentTimeRange). A path with no
+// entry yet is left alone - that should never happen, since recordManifestSegment always runs
+// first when the segment is created.
+func (s *Indexer) recordManifestTimeRange(path, field string, min, max time.Time) {
+	s.manifestLock.Lock()
+	defer s.manifestLock.Unlock()
+	entries := s.loadManifest()
+	name := filepath.Base(path)
+	e, ok := entries[name]
+	if !ok {
+		return
+	}
+	e.MinTime, e.MaxTime = min, max
+	e.RangeField = field
+	entries[name] = e
+	if err := s.saveManifest(entries); err != nil {
+		s.logPrintln("[pydio.grpc.log] Could not update segment manifest", err)
+	}
+}
+
+// removeManifestSegment drops a segment's manifest entry (e.g. after retention or Truncate
+// removed its directory) and renumbers the remaining entries' Order, then persists the manifest.
+func (s *Indexer) removeManifestSegment(path string) {
+	s.manifestLock.Lock()
+	defer s.manifestLock.Unlock()
+	entries := s.loadManifest()
+	name := filepath.Base(path)
+	if _, ok := entries[name]; !ok {
+		return
+	}
+	delete(entries, name)
+	ordered := make([]string, 0, len(entries))
+	for k := range entries {
+		ordered = append(ordered, k)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return entries[ordered[i]].Order < entries[ordered[j]].Order })
+	for i, k := range ordered {
+		e := entries[k]
+		e.Order = i
+		entries[k] = e
+	}
+	if err := s.saveManifest(entries); err != nil {
+		s.logPrintln("[pydio.grpc.log] Could not update segment manifest", err)
+	}
+}
+
+// reconcileManifest backfills manifest entries for segments found by listIndexes that the
+// manifest doesn't know about yet (segments created before this feature existed, or recovered by
+// recoverInterruptedRenumbering), using the directory's modification time as a best-effort
+// CreatedAt, and drops entries for segments that no longer exist on disk. It is called once per
+// Open so ManifestEntries always reflects what's actually on disk.
+func (s *Indexer) reconcileManifest(existing []string) {
+	s.manifestLock.Lock()
+	defer s.manifestLock.Unlock()
+	entries := s.loadManifest()
+	dirPath, _ := filepath.Split(s.indexPath)
+	changed := false
+	for _, name := range existing {
+		if _, ok := entries[name]; ok {
+			continue
+		}
+		createdAt := time.Now()
+		if info, err := os.Stat(filepath.Join(dirPath, name)); err == nil {
+			createdAt = info.ModTime()
+		}
+		entries[name] = ManifestEntry{Path: name, CreatedAt: createdAt, RotationReason: "recovered", Order: len(entries)}
+		changed = true
+	}
+	known := map[string]bool{}
+	for _, name := range existing {
+		known[name] = true
+	}
+	for name := range entries {
+		if !known[name] {
+			delete(entries, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	ordered := make([]string, 0, len(entries))
+	for k := range entries {
+		ordered = append(ordered, k)
+	}
+	sort.Strings(ordered)
+	for i, k := range ordered {
+		e := entries[k]
+		e.Order = i
+		entries[k] = e
+	}
+	if err := s.saveManifest(entries); err != nil {
+		s.logPrintln("[pydio.grpc.log] Could not update segment manifest", err)
+	}
+}
+
+// ManifestEntries returns the on-disk segment manifest, ordered the same way listIndexes orders
+// segments, so operators and retention logic can read each segment's creation time and rotation
+// reason without opening it or scanning its documents.
+func (s *Indexer) ManifestEntries() []ManifestEntry {
+	s.manifestLock.Lock()
+	defer s.manifestLock.Unlock()
+	entries := s.loadManifest()
+	ordered := make([]ManifestEntry, 0, len(entries))
+	for _, e := range entries {
+		ordered = append(ordered, e)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Order < ordered[j].Order })
+	return ordered
+}
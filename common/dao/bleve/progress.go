@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2019-2022. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package bleve
+
+// ProgressPhase identifies which long-running maintenance operation a Progress update describes.
+type ProgressPhase string
+
+const (
+	ProgressPhaseResync   ProgressPhase = "resync"
+	ProgressPhaseTruncate ProgressPhase = "truncate"
+)
+
+// Progress reports structured progress for a long-running maintenance operation (Resync,
+// Truncate), as an alternative to the free-text logger func(string) both already accept - useful
+// for a caller that wants to render a progress bar instead of parsing log lines. Total is 0 when
+// it isn't known yet (e.g. before Resync's first page comes back).
+type Progress struct {
+	Phase   ProgressPhase
+	Current int
+	Total   int
+	Message string
+}
+
+// SetProgress registers a channel that Resync and Truncate send Progress updates to, in addition
+// to whatever logger func(string) the caller passed them. It is nil-safe and optional: callers
+// that only want the string logger don't need to call this. It must be called before Resync or
+// Truncate to catch their first update. Sends are non-blocking - a caller that wants every update
+// should give the channel enough buffer, or drain it promptly.
+func (s *Indexer) SetProgress(ch chan<- Progress) {
+	s.progress = ch
+}
+
+// notifyProgress calls logger (if non-nil) and sends a Progress update on s.progress (if set,
+// non-blockingly) so callers can use either, both, or neither.
+func (s *Indexer) notifyProgress(logger func(string), phase ProgressPhase, current, total int, message string) {
+	if logger != nil {
+		logger(message)
+	}
+	if s.progress == nil {
+		return
+	}
+	select {
+	case s.progress <- Progress{Phase: phase, Current: current, Total: total, Message: message}:
+	default:
+	}
+}
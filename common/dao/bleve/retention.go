@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2019-2022. Abstrium SAS <team (at) pydio.com>
+ * This file is part of Pydio Cells.
+ *
+ * Pydio Cells is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * Pydio Cells is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with Pydio Cells.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * The latest code can be found at <https://pydio.com>.
+ */
+
+package bleve
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// RetentionPolicy combines the limits ApplyRetention enforces together: MaxSize caps total
+// on-disk usage across every segment, MaxAge caps a segment's age (read from its manifest
+// CreatedAt, see ManifestEntries), and MaxIndexes caps how many segments are kept. A zero field
+// disables that particular constraint. Whichever constraint a segment violates first, working
+// oldest-to-newest, gets it removed; the active write segment is never a candidate.
+type RetentionPolicy struct {
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxIndexes int
+}
+
+// ApplyRetention generalizes Truncate (size-only) and the RetentionMaxAge background sweep
+// (document-field age) into a single evaluation of RetentionPolicy's three constraints together.
+// It walks rotated segments oldest-first, skipping the active write segment, and removes a
+// segment as soon as any configured constraint is violated: MaxAge against its manifest
+// CreatedAt, MaxSize against the running total disk usage of everything still kept, MaxIndexes
+// against the running count of segments still kept. Segment age comes from the manifest rather
+// than a document scan, so no RetentionField/TimeField needs to be configured.
+func (s *Indexer) ApplyRetention(ctx context.Context, policy RetentionPolicy, logger func(string)) error {
+	if logger == nil {
+		logger = func(string) {}
+	}
+	if s.indexPath == "" {
+		logger("Indexer has no on-disk path, ApplyRetention is a no-op")
+		return nil
+	}
+	if policy.MaxSize <= 0 && policy.MaxAge <= 0 && policy.MaxIndexes <= 0 {
+		return fmt.Errorf("bleve: ApplyRetention requires at least one of MaxSize, MaxAge or MaxIndexes to be set")
+	}
+
+	s.flushLock.Lock()
+	snap := s.snapshotSegments()
+	s.flushLock.Unlock()
+	if len(snap.indexes) == 0 {
+		return nil
+	}
+
+	manifest := s.ManifestEntries()
+	createdAt := make(map[string]time.Time, len(manifest))
+	for _, e := range manifest {
+		createdAt[e.Path] = e.CreatedAt
+	}
+
+	// candidates is oldest-first: snap.indexes is built from listIndexes's sorted order, and the
+	// write segment is always last, so dropping it leaves the rest already oldest-to-newest.
+	var candidates []bleve.Index
+	var total int64
+	usage := make(map[bleve.Index]int64, len(snap.indexes))
+	for i, idx := range snap.indexes {
+		u, _ := indexDiskUsage(idx.Name())
+		usage[idx] = u
+		total += u
+		if i != snap.cursor {
+			candidates = append(candidates, idx)
+		}
+	}
+
+	count := len(snap.indexes)
+	now := time.Now()
+	for _, idx := range candidates {
+		violates := false
+		if policy.MaxAge > 0 {
+			if ts, ok := createdAt[filepath.Base(idx.Name())]; ok && now.Sub(ts) > policy.MaxAge {
+				violates = true
+			}
+		}
+		if !violates && policy.MaxSize > 0 && total > policy.MaxSize {
+			violates = true
+		}
+		if !violates && policy.MaxIndexes > 0 && count > policy.MaxIndexes {
+			violates = true
+		}
+		if !violates {
+			continue
+		}
+		total -= usage[idx]
+		count--
+		s.removeRotatedSegment(idx, "segment over retention policy")
+	}
+	s.refreshTotalDiskUsage()
+	return nil
+}
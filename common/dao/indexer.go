@@ -34,6 +34,51 @@ type IndexIDProvider interface {
 	IndexID() string
 }
 
+// SegmentAwareUnmarshaler adds additional capacities to IndexCodex for decoding a result
+// alongside the identity of the underlying segment/index it was found in (e.g. for diagnosing
+// why a stale document still shows up, or for targeting segment-specific operations).
+type SegmentAwareUnmarshaler interface {
+	UnmarshalWithSegment(indexed interface{}, segment string) (interface{}, error)
+}
+
+// HighlightReceiver is implemented by result objects returned from IndexCodex.Unmarshal that can
+// carry highlighted field fragments back to the caller. When a search hit carries fragments (the
+// query enabled highlighting via BuildQuery/BuildQueryOptions), FindMany and its variants call
+// SetHighlightFragments with them, keyed by field name, right after Unmarshal returns the result.
+type HighlightReceiver interface {
+	SetHighlightFragments(fragments map[string][]string)
+}
+
+// HighlightParser is an alternative to HighlightReceiver for codecs whose result type is built
+// once and not mutated afterwards: instead of calling Unmarshal then SetHighlightFragments,
+// FindManyWithHighlight calls UnmarshalWithHighlight directly, passing the hit's fragments (keyed
+// by field name) alongside the raw indexed value so the codec can bake them into the result at
+// construction time. FindMany and its other variants do not call it; they use Unmarshal and
+// HighlightReceiver.
+type HighlightParser interface {
+	UnmarshalWithHighlight(indexed interface{}, fragments map[string][]string) (interface{}, error)
+}
+
+// AggregationResult carries numeric statistics computed over a field, as produced by
+// FindManyWithAggregation. Unlike bleve's native facets, which only ever report bucket counts
+// (plus the caller-supplied range bounds, echoed back as Min/Max), Sum/Avg/Min/Max here are
+// genuinely computed from the field's values - but only across Count documents, i.e. whatever
+// page of hits FindManyWithAggregation actually fetched, not the full match set.
+type AggregationResult struct {
+	Field string
+	Count int
+	Sum   float64
+	Avg   float64
+	Min   float64
+	Max   float64
+}
+
+// AggregationParser adds additional capacities to IndexCodex for receiving the output of
+// FindManyWithAggregation, analogous to how FacetParser receives bleve's native facets.
+type AggregationParser interface {
+	UnmarshalAggregation(agg AggregationResult)
+}
+
 // IndexDAO is a rich DAO with ready-to-use inserts + search capacities.
 // It must be initialised with a proper DAO and a proper IndexCodex.
 type IndexDAO interface {